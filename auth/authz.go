@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	kitHttp "git.jetbrains.space/orbi/fcsd/kit/http"
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+)
+
+// AuthzConfig is authorization configuration
+type AuthzConfig struct {
+	// RolePermissions - permissions granted to roles
+	RolePermissions map[string][]string `config:"role-permissions"`
+	// SuperRoles - roles passing all checks including ownership
+	SuperRoles []string `config:"super-roles"`
+}
+
+// OwnerFunc retrieves id of the user owning requested resource from gRPC request
+type OwnerFunc func(req interface{}) string
+
+// Rule specifies access requirements
+// user must have any of Roles and all of Permissions
+// if owner is specified (OwnerVar for HTTP, Owner for gRPC unary calls), the owner of resource is granted access regardless of roles and permissions
+// and others must satisfy Roles and Permissions, if they are empty only the owner is granted access
+// (so nobody except super roles is granted access if owner resolves to empty, e.g. for gRPC streams)
+type Rule struct {
+	// Roles - user must have any of roles
+	Roles []string
+	// Permissions - user must have all permissions
+	Permissions []string
+	// OwnerVar - URL variable containing owner's user id, http.Me alias is supported
+	OwnerVar string
+	// Owner - retrieves owner's user id from gRPC request
+	Owner OwnerFunc
+}
+
+// Authorizer checks access rules against roles of request context
+type Authorizer struct {
+	sync.RWMutex
+	cfg     *AuthzConfig
+	methods map[string]*Rule
+}
+
+func NewAuthorizer(cfg *AuthzConfig) *Authorizer {
+	if cfg == nil {
+		cfg = &AuthzConfig{}
+	}
+	return &Authorizer{
+		cfg:     cfg,
+		methods: map[string]*Rule{},
+	}
+}
+
+// Permissions returns permissions granted to the roles
+func (a *Authorizer) Permissions(roles []string) map[string]struct{} {
+	res := map[string]struct{}{}
+	for _, r := range roles {
+		for _, p := range a.cfg.RolePermissions[r] {
+			res[p] = struct{}{}
+		}
+	}
+	return res
+}
+
+func (a *Authorizer) isSuper(roles []string) bool {
+	for _, r := range roles {
+		if contains(a.cfg.SuperRoles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerOnly returns true if only the owner is granted access
+func (r *Rule) ownerOnly() bool {
+	return (r.OwnerVar != "" || r.Owner != nil) && len(r.Roles) == 0 && len(r.Permissions) == 0
+}
+
+// Check checks if the current user satisfies roles and permissions of the rule
+// owner is id of the resource owner, aliases (e.g. http.Me) must be resolved by the caller
+// if the rule declares owner source and grants access to the owner only, empty owner is denied
+func (a *Authorizer) Check(ctx context.Context, rule *Rule, owner string) error {
+
+	rCtx, ok := kitContext.Request(ctx)
+	if !ok || rCtx.GetUserId() == "" {
+		return ErrAuthNoUser(ctx)
+	}
+	roles := rCtx.GetRoles()
+
+	if a.isSuper(roles) {
+		return nil
+	}
+
+	if owner != "" && owner == rCtx.GetUserId() {
+		return nil
+	}
+	if (owner != "" && len(rule.Roles) == 0 && len(rule.Permissions) == 0) || rule.ownerOnly() {
+		return ErrAuthNotOwner(ctx, owner)
+	}
+
+	if len(rule.Roles) > 0 {
+		found := false
+		for _, r := range rule.Roles {
+			if contains(roles, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrAuthForbidden(ctx, rule.Roles, rule.Permissions)
+		}
+	}
+
+	if len(rule.Permissions) > 0 {
+		granted := a.Permissions(roles)
+		for _, p := range rule.Permissions {
+			if _, ok := granted[p]; !ok {
+				return ErrAuthForbidden(ctx, rule.Roles, rule.Permissions)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Handler wraps handler with the rule check
+// errors are responded by the controller, default controller is used if nil
+func (a *Authorizer) Handler(c *kitHttp.BaseController, rule *Rule, next http.Handler) http.Handler {
+	if c == nil {
+		c = &kitHttp.BaseController{}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var owner string
+		if rule.OwnerVar != "" {
+			owner = mux.Vars(r)[rule.OwnerVar]
+			// http.Me alias of URL is resolved to the current user, it means nothing for other sources of owner
+			if owner == kitHttp.Me {
+				if rCtx, ok := kitContext.Request(r.Context()); ok {
+					owner = rCtx.GetUserId()
+				}
+			}
+		}
+		if err := a.Check(r.Context(), rule, owner); err != nil {
+			c.RespondError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Require returns middleware checking the rule, it can be applied to subrouters
+func (a *Authorizer) Require(c *kitHttp.BaseController, rule *Rule) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return a.Handler(c, rule, next)
+	}
+}
+
+// Protect attaches the rule to the route, it must be called after the route handler is set
+//
+//	a.Protect(c, r.HandleFunc("/users/{userId}", h).Methods("GET"), &auth.Rule{OwnerVar: "userId", Roles: []string{"admin"}})
+func (a *Authorizer) Protect(c *kitHttp.BaseController, route *mux.Route, rule *Rule) *mux.Route {
+	return route.Handler(a.Handler(c, rule, route.GetHandler()))
+}
+
+// Method attaches the rule to gRPC full method name (e.g. /package.Service/Method)
+func (a *Authorizer) Method(fullMethod string, rule *Rule) *Authorizer {
+	a.Lock()
+	defer a.Unlock()
+	a.methods[fullMethod] = rule
+	return a
+}
+
+func (a *Authorizer) methodRule(fullMethod string) (*Rule, bool) {
+	a.RLock()
+	defer a.RUnlock()
+	rule, ok := a.methods[fullMethod]
+	return rule, ok
+}
+
+// UnaryServerInterceptor checks rules attached to gRPC methods, methods without rules aren't checked
+// it must be called after authentication interceptor
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if rule, ok := a.methodRule(info.FullMethod); ok {
+			var owner string
+			if rule.Owner != nil {
+				owner = rule.Owner(req)
+			}
+			if err := a.Check(ctx, rule, owner); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor checks rules attached to gRPC stream methods, ownership isn't checked for streams
+func (a *Authorizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if rule, ok := a.methodRule(info.FullMethod); ok {
+			if err := a.Check(ss.Context(), rule, ""); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+func contains(s []string, v string) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
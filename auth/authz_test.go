@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	kitGrpc "git.jetbrains.space/orbi/fcsd/kit/grpc"
+	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func Test_Check(t *testing.T) {
+	a := NewAuthorizer(&AuthzConfig{
+		RolePermissions: map[string][]string{"editor": {"doc.read", "doc.write"}, "viewer": {"doc.read"}},
+		SuperRoles:      []string{"admin"},
+	})
+	ctx := func(roles ...string) context.Context {
+		return kitContext.NewRequestCtx().WithUser("u1", "").WithRoles(roles...).ToContext(context.Background())
+	}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		rule  *Rule
+		owner string
+		code  string
+	}{
+		{"no user", context.Background(), &Rule{}, "", ErrCodeAuthNoUser},
+		{"role", ctx("viewer"), &Rule{Roles: []string{"viewer", "editor"}}, "", ""},
+		{"no role", ctx("viewer"), &Rule{Roles: []string{"editor"}}, "", ErrCodeAuthForbidden},
+		{"permission", ctx("editor"), &Rule{Permissions: []string{"doc.write"}}, "", ""},
+		{"no permission", ctx("viewer"), &Rule{Permissions: []string{"doc.write"}}, "", ErrCodeAuthForbidden},
+		{"super role", ctx("admin"), &Rule{Permissions: []string{"doc.write"}}, "u2", ""},
+		{"owner", ctx(), &Rule{Roles: []string{"editor"}}, "u1", ""},
+		{"me isn't resolved", ctx(), &Rule{}, "me", ErrCodeAuthNotOwner},
+		{"not owner", ctx("viewer"), &Rule{}, "u2", ErrCodeAuthNotOwner},
+		{"not owner with role", ctx("editor"), &Rule{Roles: []string{"editor"}}, "u2", ""},
+		{"empty owner var", ctx("viewer"), &Rule{OwnerVar: "userId"}, "", ErrCodeAuthNotOwner},
+		{"empty owner func", ctx("viewer"), &Rule{Owner: func(interface{}) string { return "" }}, "", ErrCodeAuthNotOwner},
+		{"empty owner with role", ctx("editor"), &Rule{OwnerVar: "userId", Roles: []string{"editor"}}, "", ""},
+		{"empty owner super role", ctx("admin"), &Rule{OwnerVar: "userId"}, "", ""},
+		{"no owner source", ctx(), &Rule{}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.Check(tt.ctx, tt.rule, tt.owner)
+			if tt.code == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.code != "" && !errors.Is(err, er.Code(tt.code)) {
+				t.Fatalf("%s expected, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func Test_Protect(t *testing.T) {
+	a := NewAuthorizer(nil)
+	r := mux.NewRouter()
+	a.Protect(nil, r.HandleFunc("/users/{userId}", func(w http.ResponseWriter, r *http.Request) {}), &Rule{OwnerVar: "userId"})
+	// owner var is missing in the route
+	a.Protect(nil, r.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {}), &Rule{OwnerVar: "userId"})
+
+	ctx := kitContext.NewRequestCtx().WithUser("u1", "").ToContext(context.Background())
+	for path, status := range map[string]int{"/users/u1": http.StatusOK, "/users/me": http.StatusOK, "/users/u2": http.StatusForbidden, "/users": http.StatusForbidden} {
+		rs := httptest.NewRecorder()
+		r.ServeHTTP(rs, httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx))
+		if rs.Code != status {
+			t.Fatalf("%s: %d expected, got %d", path, status, rs.Code)
+		}
+	}
+}
+
+// watchHealthServer responds to Watch with serving status
+type watchHealthServer struct {
+	healthpb.HealthServer
+}
+
+func (w *watchHealthServer) Watch(rq *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
+
+func Test_GrpcInterceptors(t *testing.T) {
+	v, err := NewVerifier(&JwtConfig{Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuthorizer(nil).
+		Method("/grpc.health.v1.Health/Check", &Rule{Owner: func(req interface{}) string { return req.(*healthpb.HealthCheckRequest).Service }}).
+		Method("/grpc.health.v1.Health/Watch", &Rule{Roles: []string{"editor"}})
+
+	logger := log.Init(&log.Config{Level: log.ErrorLevel})
+	s, err := kitGrpc.NewServer("test", func() log.CLogger { return log.L(logger) }, &kitGrpc.ServerConfig{
+		UnaryInterceptors:  []grpc.UnaryServerInterceptor{v.UnaryServerInterceptor(), a.UnaryServerInterceptor()},
+		StreamInterceptors: []grpc.StreamServerInterceptor{v.StreamServerInterceptor(), a.StreamServerInterceptor()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := kitGrpc.NewHealthServer()
+	hs.SetServingStatus("u1", healthpb.HealthCheckResponse_SERVING)
+	s.HealthServer = &watchHealthServer{HealthServer: hs}
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = s.Srv.Serve(lis) }()
+	t.Cleanup(s.Close)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	cl := healthpb.NewHealthClient(conn)
+
+	withToken := func(roles ...string) context.Context {
+		token := sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix(), "sub": "u1", "roles": roles})
+		return metadata.AppendToOutgoingContext(context.Background(), MdAuthorization, "Bearer "+token)
+	}
+	assertStatus := func(err error, code codes.Code, reason string) {
+		t.Helper()
+		st := status.Convert(err)
+		if st.Code() != code {
+			t.Fatalf("%v expected, got %v", code, err)
+		}
+		for _, d := range st.Details() {
+			if info, ok := d.(*errdetails.ErrorInfo); ok && info.Reason == reason {
+				return
+			}
+		}
+		t.Fatalf("%s expected, got %v", reason, st.Details())
+	}
+
+	if _, err := cl.Check(withToken(), &healthpb.HealthCheckRequest{Service: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	// "me" of request body isn't an alias of the current user
+	_, err = cl.Check(withToken(), &healthpb.HealthCheckRequest{Service: "me"})
+	assertStatus(err, codes.PermissionDenied, ErrCodeAuthNotOwner)
+	// empty owner isn't granted access
+	_, err = cl.Check(withToken(), &healthpb.HealthCheckRequest{})
+	assertStatus(err, codes.PermissionDenied, ErrCodeAuthNotOwner)
+
+	watch := func(ctx context.Context) error {
+		stream, err := cl.Watch(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+	assertStatus(watch(withToken("viewer")), codes.PermissionDenied, ErrCodeAuthForbidden)
+	assertStatus(watch(context.Background()), codes.Unauthenticated, ErrCodeAuthNoToken)
+	if err := watch(withToken("editor")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+)
+
+const (
+	ErrCodeAuthNoToken           = "AUTH-001"
+	ErrCodeAuthInvalidToken      = "AUTH-002"
+	ErrCodeAuthKeyLoad           = "AUTH-003"
+	ErrCodeAuthJwksFetch         = "AUTH-004"
+	ErrCodeAuthNoKey             = "AUTH-005"
+	ErrCodeAuthNoUser            = "AUTH-006"
+	ErrCodeAuthForbidden         = "AUTH-007"
+	ErrCodeAuthNotOwner          = "AUTH-008"
+	ErrCodeAuthTenantMismatch    = "AUTH-009"
+	ErrCodeAuthTenantNotVerified = "AUTH-010"
+)
+
+var (
+	ErrAuthNoToken = func(ctx context.Context) error {
+		return er.WithCode(ErrCodeAuthNoToken).C(ctx).Err()
+	}
+	ErrAuthInvalidToken = func(cause error, ctx context.Context) error {
+		return er.WrapWithCode(cause, ErrCodeAuthInvalidToken).C(ctx).Err()
+	}
+	ErrAuthKeyLoad = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeAuthKeyLoad).Err()
+	}
+	ErrAuthJwksFetch = func(cause error, src string) error {
+		return er.WrapWithCode(cause, ErrCodeAuthJwksFetch).F(er.FF{"src": src}).Err()
+	}
+	ErrAuthNoKey = func(kid string) error {
+		return er.WithCode(ErrCodeAuthNoKey).F(er.FF{"kid": kid}).Err()
+	}
+	ErrAuthNoUser = func(ctx context.Context) error {
+		return er.WithCode(ErrCodeAuthNoUser).C(ctx).Err()
+	}
+	ErrAuthForbidden = func(ctx context.Context, roles, permissions []string) error {
+		return er.WithCode(ErrCodeAuthForbidden).C(ctx).F(er.FF{"roles": roles, "permissions": permissions}).Err()
+	}
+	ErrAuthNotOwner = func(ctx context.Context, owner string) error {
+		return er.WithCode(ErrCodeAuthNotOwner).C(ctx).F(er.FF{"owner": owner}).Err()
+	}
+	ErrAuthTenantMismatch = func(ctx context.Context, tokenTenant string) error {
+		return er.WithCode(ErrCodeAuthTenantMismatch).C(ctx).F(er.FF{"token-tenant": tokenTenant}).Err()
+	}
+	ErrAuthTenantNotVerified = func(cause error, ctx context.Context, tenantId string) error {
+		return er.WithCode(ErrCodeAuthTenantNotVerified).Cause(cause).C(ctx).F(er.FF{"tenant": tenantId}).Err()
+	}
+)
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeAuthNoToken, Message: "authorization token is missing", HttpStatus: http.StatusUnauthorized, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeAuthInvalidToken, Message: "invalid token", HttpStatus: http.StatusUnauthorized, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeAuthKeyLoad, Message: "load verification key", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeAuthJwksFetch, Message: "fetch jwks"},
+		&er.CodeDef{Code: ErrCodeAuthNoKey, Message: "verification key not found"},
+		&er.CodeDef{Code: ErrCodeAuthNoUser, Message: "user isn't authenticated", HttpStatus: http.StatusUnauthorized, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeAuthForbidden, Message: "access denied", HttpStatus: http.StatusForbidden, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeAuthNotOwner, Message: "access to resource of another user denied", HttpStatus: http.StatusForbidden, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeAuthTenantMismatch, Message: "token isn't issued for the tenant", HttpStatus: http.StatusForbidden, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeAuthTenantNotVerified, Message: "user's membership in the tenant isn't confirmed", HttpStatus: http.StatusForbidden, Severity: er.SeverityWarning},
+	)
+}
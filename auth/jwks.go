@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// jwksMinRefreshInterval limits refreshing JWKS when token with unknown kid is received
+const jwksMinRefreshInterval = time.Second * 30
+
+// jwk is a JSON Web Key (RFC 7517), only public RSA and EC keys are supported
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []*jwk `json:"keys"`
+}
+
+// jwksCache keeps keys loaded from JWKS file or endpoint
+type jwksCache struct {
+	sync.Mutex
+	file      string
+	url       string
+	ttl       time.Duration
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	client    *http.Client
+}
+
+func newJwksCache(file, url string, ttl time.Duration) *jwksCache {
+	return &jwksCache{
+		file:   file,
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// key returns key by kid, keys are refreshed if cache is expired or kid is unknown
+// if kid is empty and there is the only key, it's returned
+func (c *jwksCache) key(kid string) (interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	since := time.Since(c.fetchedAt)
+	key, found := c.find(kid)
+	if since > c.ttl || (!found && since > jwksMinRefreshInterval) {
+		if err := c.refreshLocked(); err != nil {
+			// stale keys are still used if refreshing failed
+			if found {
+				return key, nil
+			}
+			return nil, err
+		}
+		key, found = c.find(kid)
+	}
+
+	if !found {
+		return nil, ErrAuthNoKey(kid)
+	}
+	return key, nil
+}
+
+func (c *jwksCache) find(kid string) (interface{}, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k, true
+		}
+	}
+	k, ok := c.keys[kid]
+	return k, ok
+}
+
+func (c *jwksCache) refresh() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.refreshLocked()
+}
+
+func (c *jwksCache) refreshLocked() error {
+
+	src := c.file
+	var data []byte
+	var err error
+	if c.file != "" {
+		data, err = ioutil.ReadFile(c.file)
+	} else {
+		src = c.url
+		data, err = c.fetch()
+	}
+	if err != nil {
+		return ErrAuthJwksFetch(err, src)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return ErrAuthJwksFetch(err, src)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return ErrAuthJwksFetch(err, src)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	return nil
+}
+
+func (c *jwksCache) fetch() ([]byte, error) {
+	rs, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rs.Body.Close() }()
+	if rs.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", rs.StatusCode)
+	}
+	return ioutil.ReadAll(rs.Body)
+}
+
+// publicKey converts JWK to public key, unsupported key types are skipped
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64Int(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64Int(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curve %s isn't supported", k.Crv)
+		}
+		x, err := base64Int(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64Int(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+func base64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
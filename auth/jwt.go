@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	DefaultJwksCacheTtl = time.Minute * 10
+)
+
+var (
+	hmacAlgorithms = []string{"HS256", "HS384", "HS512"}
+	keyAlgorithms  = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+// JwtConfig specifies how tokens are verified
+// at least one of Secret, PublicKeyFile, JwksFile, JwksUrl must be specified
+type JwtConfig struct {
+	// Secret - HMAC secret for HS* algorithms
+	Secret string `config:"secret"`
+	// PublicKeyFile - PEM encoded RSA or ECDSA public key for RS*, PS*, ES* algorithms
+	PublicKeyFile string `config:"public-key-file"`
+	// JwksFile - JSON Web Key Set file, keys are selected by kid
+	JwksFile string `config:"jwks-file"`
+	// JwksUrl - JSON Web Key Set endpoint, keys are selected by kid
+	JwksUrl string `config:"jwks-url"`
+	// JwksCacheTtl - how long JWKS is cached, DefaultJwksCacheTtl if empty
+	JwksCacheTtl time.Duration `config:"jwks-cache-ttl"`
+	// Algorithms - allowed algorithms, by default all algorithms supported by configured keys
+	Algorithms []string `config:"algorithms"`
+	// Issuer - expected iss claim, not checked if empty
+	Issuer string `config:"issuer"`
+	// Audience - expected aud claim, not checked if empty
+	Audience string `config:"audience"`
+	// Leeway - allowed clock skew when checking exp, nbf, iat
+	Leeway time.Duration `config:"leeway"`
+	// Claims - names of claims mapped to request context
+	Claims *ClaimsConfig `config:"claims"`
+	// TenantMembership - checks if the user belongs to the tenant requested by the client, when the token doesn't carry the tenant claim
+	TenantMembership TenantMembershipFunc `config:"-"`
+	// PublicTenant - validates tenant requested by the client on public gRPC methods (not requiring token)
+	// tenant can't be verified by token there, so if it's nil, requests with tenant are rejected on public methods
+	PublicTenant PublicTenantFunc `config:"-"`
+}
+
+// TenantMembershipFunc checks if the user of verified claims belongs to the tenant, error denies access
+type TenantMembershipFunc func(ctx context.Context, claims jwt.MapClaims, tenantId string) error
+
+// PublicTenantFunc validates tenant requested by anonymous client, error denies access
+type PublicTenantFunc func(ctx context.Context, tenantId string) error
+
+// ClaimsConfig specifies names of claims mapped to request context
+// nested claims are specified with dots (e.g. realm_access.roles), claim isn't mapped if name is empty
+type ClaimsConfig struct {
+	UserId        string `config:"user-id"`         // UserId - "sub" by default
+	Username      string `config:"username"`        // Username - "preferred_username" by default
+	UserSessionId string `config:"user-session-id"` // UserSessionId - "sid" by default
+	SessionId     string `config:"session-id"`      // SessionId - not mapped by default
+	ChatUserId    string `config:"chat-user-id"`    // ChatUserId - not mapped by default
+	Roles         string `config:"roles"`           // Roles - "roles" by default, array or space separated string
+	TenantId      string `config:"tenant-id"`       // TenantId - "tenant_id" by default, the token is accepted only for its tenant
+}
+
+// DefaultClaims is used if claims aren't configured
+var DefaultClaims = &ClaimsConfig{
+	UserId:        "sub",
+	Username:      "preferred_username",
+	UserSessionId: "sid",
+	Roles:         "roles",
+	TenantId:      "tenant_id",
+}
+
+// Verifier verifies JWT and populates request context from claims
+type Verifier struct {
+	cfg       *JwtConfig
+	claims    *ClaimsConfig
+	parser    *jwt.Parser
+	staticKey interface{}
+	jwks      *jwksCache
+}
+
+// NewVerifier creates a new verifier loading keys specified in config
+func NewVerifier(cfg *JwtConfig) (*Verifier, error) {
+
+	v := &Verifier{
+		cfg:    cfg,
+		claims: cfg.Claims,
+	}
+	if v.claims == nil {
+		v.claims = DefaultClaims
+	}
+
+	algorithms := cfg.Algorithms
+	if cfg.Secret != "" && len(cfg.Algorithms) == 0 {
+		algorithms = append(algorithms, hmacAlgorithms...)
+	}
+
+	if cfg.PublicKeyFile != "" {
+		key, err := loadPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, ErrAuthKeyLoad(err)
+		}
+		v.staticKey = key
+	}
+
+	if cfg.JwksFile != "" || cfg.JwksUrl != "" {
+		ttl := cfg.JwksCacheTtl
+		if ttl <= 0 {
+			ttl = DefaultJwksCacheTtl
+		}
+		v.jwks = newJwksCache(cfg.JwksFile, cfg.JwksUrl, ttl)
+		// fail fast if JWKS isn't available on start
+		if err := v.jwks.refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	if (v.staticKey != nil || v.jwks != nil) && len(cfg.Algorithms) == 0 {
+		algorithms = append(algorithms, keyAlgorithms...)
+	}
+
+	if len(algorithms) == 0 {
+		return nil, ErrAuthKeyLoad(fmt.Errorf("no verification keys configured"))
+	}
+
+	v.parser = &jwt.Parser{
+		ValidMethods: algorithms,
+		// time based claims are validated with leeway
+		SkipClaimsValidation: true,
+	}
+
+	return v, nil
+}
+
+// Verify verifies token signature and claims
+func (v *Verifier) Verify(ctx context.Context, token string) (jwt.MapClaims, error) {
+
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, ErrAuthInvalidToken(err, ctx)
+	}
+
+	if err := v.validate(claims); err != nil {
+		return nil, ErrAuthInvalidToken(err, ctx)
+	}
+
+	return claims, nil
+}
+
+// Authenticate verifies token and returns context with request context populated from claims
+// if there is no request context, a new one is created
+func (v *Verifier) Authenticate(ctx context.Context, token string) (context.Context, error) {
+
+	claims, err := v.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	rCtx, ok := kitContext.Request(ctx)
+	if !ok {
+		rCtx = kitContext.NewRequestCtx().WithNewRequestId()
+	}
+
+	filled := v.FillRequestContext(rCtx, claims)
+
+	// tenant requested by the client (header, subdomain, metadata) is granted only if it's the tenant of the token or membership is confirmed
+	if requested := rCtx.GetTenantId(); requested != "" {
+		tokenTenant := filled.GetTenantId()
+		switch {
+		case tokenTenant != "":
+			if tokenTenant != requested {
+				return nil, ErrAuthTenantMismatch(ctx, tokenTenant)
+			}
+		case v.cfg.TenantMembership != nil:
+			if err := v.cfg.TenantMembership(ctx, claims, requested); err != nil {
+				return nil, ErrAuthTenantNotVerified(err, ctx, requested)
+			}
+			filled = filled.WithTenant(requested)
+		default:
+			return nil, ErrAuthTenantNotVerified(nil, ctx, requested)
+		}
+	}
+
+	return filled.ToContext(ctx), nil
+}
+
+// FillRequestContext returns a copy of request context with identity populated from claims only
+// user, sessions, chat user, roles and tenant of the request context are dropped, as they may be set by the client (e.g. rq-bin metadata)
+func (v *Verifier) FillRequestContext(rCtx *kitContext.RequestContext, claims jwt.MapClaims) *kitContext.RequestContext {
+	rCtx = rCtx.WithoutIdentity()
+	if s := claimString(claims, v.claims.UserId); s != "" {
+		rCtx = rCtx.WithUser(s, rCtx.GetUsername())
+	}
+	if s := claimString(claims, v.claims.Username); s != "" {
+		rCtx = rCtx.WithUser(rCtx.GetUserId(), s)
+	}
+	if s := claimString(claims, v.claims.UserSessionId); s != "" {
+		rCtx = rCtx.WithUserSession(s)
+	}
+	if s := claimString(claims, v.claims.SessionId); s != "" {
+		rCtx = rCtx.WithSessionId(s)
+	}
+	if s := claimString(claims, v.claims.ChatUserId); s != "" {
+		rCtx = rCtx.WithChatUserId(s)
+	}
+	if roles := claimStrings(claims, v.claims.Roles); len(roles) > 0 {
+		rCtx = rCtx.WithRoles(roles...)
+	}
+	if s := claimString(claims, v.claims.TenantId); kitContext.IsValidTenantId(s) {
+		rCtx = rCtx.WithTenant(s)
+	}
+	return rCtx
+}
+
+// key returns key verifying the token
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if v.cfg.Secret == "" {
+			return nil, fmt.Errorf("hmac isn't allowed")
+		}
+		return []byte(v.cfg.Secret), nil
+	}
+
+	if v.jwks != nil {
+		kid, _ := token.Header["kid"].(string)
+		if key, err := v.jwks.key(kid); err == nil || v.staticKey == nil {
+			return key, err
+		}
+	}
+
+	if v.staticKey != nil {
+		return v.staticKey, nil
+	}
+
+	return nil, fmt.Errorf("no key for %s", token.Method.Alg())
+}
+
+// validate checks time based claims with leeway, issuer and audience
+func (v *Verifier) validate(claims jwt.MapClaims) error {
+
+	now := time.Now()
+
+	exp, ok := claimTime(claims, "exp")
+	if !ok {
+		return fmt.Errorf("exp claim is missing")
+	}
+	if now.After(exp.Add(v.cfg.Leeway)) {
+		return fmt.Errorf("token is expired")
+	}
+	if nbf, ok := claimTime(claims, "nbf"); ok && now.Add(v.cfg.Leeway).Before(nbf) {
+		return fmt.Errorf("token isn't valid yet")
+	}
+	if iat, ok := claimTime(claims, "iat"); ok && now.Add(v.cfg.Leeway).Before(iat) {
+		return fmt.Errorf("token is used before issued")
+	}
+	if v.cfg.Issuer != "" && !claims.VerifyIssuer(v.cfg.Issuer, true) {
+		return fmt.Errorf("invalid issuer")
+	}
+	if v.cfg.Audience != "" && !claims.VerifyAudience(v.cfg.Audience, true) {
+		return fmt.Errorf("invalid audience")
+	}
+
+	return nil
+}
+
+func claimTime(claims jwt.MapClaims, name string) (time.Time, bool) {
+	if f, ok := claims[name].(float64); ok {
+		return time.Unix(int64(f), 0), true
+	}
+	return time.Time{}, false
+}
+
+// claim finds claim by dotted path
+func claim(claims jwt.MapClaims, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	var cur interface{} = map[string]interface{}(claims)
+	for _, p := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[p]
+	}
+	return cur
+}
+
+func claimString(claims jwt.MapClaims, path string) string {
+	switch c := claim(claims, path).(type) {
+	case string:
+		return c
+	case float64:
+		return fmt.Sprint(c)
+	}
+	return ""
+}
+
+func claimStrings(claims jwt.MapClaims, path string) []string {
+	switch c := claim(claims, path).(type) {
+	case string:
+		return strings.Fields(c)
+	case []interface{}:
+		var res []string
+		for _, i := range c {
+			if s, ok := i.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// loadPublicKey loads PEM encoded RSA or ECDSA public key or certificate
+func loadPublicKey(file string) (interface{}, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", file)
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
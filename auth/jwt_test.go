@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	kitHttp "git.jetbrains.space/orbi/fcsd/kit/http"
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func Test_Hmac(t *testing.T) {
+	v, err := NewVerifier(&JwtConfig{Secret: "secret", Issuer: "iss", Audience: "aud"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Add(time.Minute).Unix()
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		valid  bool
+	}{
+		{"valid", jwt.MapClaims{"exp": exp, "iss": "iss", "aud": "aud"}, true},
+		{"no exp", jwt.MapClaims{"iss": "iss", "aud": "aud"}, false},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix(), "iss": "iss", "aud": "aud"}, false},
+		{"wrong issuer", jwt.MapClaims{"exp": exp, "iss": "other", "aud": "aud"}, false},
+		{"wrong audience", jwt.MapClaims{"exp": exp, "iss": "iss", "aud": []string{"other"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodHS256, []byte("secret"), "", tt.claims))
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.valid && !errors.Is(err, er.Code(ErrCodeAuthInvalidToken)) {
+				t.Fatalf("invalid token error expected, got %v", err)
+			}
+		})
+	}
+
+	// wrong secret
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodHS256, []byte("wrong"), "", jwt.MapClaims{"exp": exp})); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func Test_JwksAndRequestContext(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, _ := json.Marshal(&jwks{Keys: []*jwk{{
+		Kty: "EC",
+		Kid: "k1",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}}})
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(file, set, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewVerifier(&JwtConfig{JwksFile: file, Claims: &ClaimsConfig{UserId: "sub", Roles: "realm_access.roles"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := sign(t, jwt.SigningMethodES256, key, "k1", jwt.MapClaims{
+		"exp":          time.Now().Add(time.Minute).Unix(),
+		"sub":          "user",
+		"realm_access": map[string]interface{}{"roles": []string{"admin", "user"}},
+	})
+
+	// HMAC token isn't accepted as there is no secret
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix()})); err == nil {
+		t.Fatal("error expected")
+	}
+
+	var rCtx *kitContext.RequestContext
+	h := v.HttpMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rCtx, _ = kitContext.Request(r.Context())
+	}))
+
+	rs := httptest.NewRecorder()
+	h.ServeHTTP(rs, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rs.Code != http.StatusUnauthorized || rs.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("401 expected, got %d", rs.Code)
+	}
+
+	rq := httptest.NewRequest(http.MethodGet, "/", nil)
+	rq.Header.Set(HeaderAuthorization, "Bearer "+token)
+	h.ServeHTTP(httptest.NewRecorder(), rq)
+	if rCtx == nil || rCtx.GetUserId() != "user" || len(rCtx.GetRoles()) != 2 || rCtx.GetRequestId() == "" {
+		t.Fatalf("unexpected request context %v", rCtx)
+	}
+}
+
+func Test_TenantClaim(t *testing.T) {
+	v, err := NewVerifier(&JwtConfig{Secret: "secret", Claims: &ClaimsConfig{UserId: "sub", TenantId: "tid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix(), "sub": "user", "tid": "a"})
+
+	ctx, err := v.Authenticate(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tid, _ := kitContext.Tenant(ctx); tid != "a" {
+		t.Fatalf("tenant expected, got %s", tid)
+	}
+
+	// tenant "b" resolved from the request, token of tenant "a"
+	ctx = kitContext.NewRequestCtx().WithNewRequestId().WithTenant("b").ToContext(context.Background())
+	if _, err := v.Authenticate(ctx, token); err == nil {
+		t.Fatal("token of another tenant must be rejected")
+	}
+}
+
+func Test_ForgedIdentity(t *testing.T) {
+	v, err := NewVerifier(&JwtConfig{Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix(), "sub": "user"})
+
+	// client forges request context metadata alongside a valid token without roles
+	forged := kitContext.NewRequestCtx().WithRequestId("rid").WithUser("x", "root").WithRoles("admin").WithSessionId("sid")
+	md, _ := kitContext.FromContextToGrpcMD(forged.ToContext(context.Background()))
+	md.Set(MdAuthorization, "Bearer "+token)
+	ctx := kitContext.FromGrpcMD(metadata.NewIncomingContext(context.Background(), md), md)
+
+	var rCtx *kitContext.RequestContext
+	_, err = v.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		rCtx, _ = kitContext.Request(ctx)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rCtx.GetUserId() != "user" || rCtx.GetUsername() != "" || len(rCtx.GetRoles()) != 0 || rCtx.GetSessionId() != "" || rCtx.GetRequestId() != "rid" {
+		t.Fatalf("identity must be taken from the token only %+v", rCtx)
+	}
+
+	a := NewAuthorizer(&AuthzConfig{SuperRoles: []string{"admin"}})
+	if err := a.Check(rCtx.ToContext(context.Background()), &Rule{Roles: []string{"admin"}}, ""); err == nil {
+		t.Fatal("forged roles mustn't be granted")
+	}
+}
+
+func Test_CrossTenant(t *testing.T) {
+	// default claims config
+	v, err := NewVerifier(&JwtConfig{Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Minute).Unix()
+	tokenA := sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"exp": exp, "sub": "user", "tenant_id": "a"})
+	noTenant := sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"exp": exp, "sub": "user"})
+
+	var tenantId string
+	h := kitHttp.TenantMiddleware(nil, nil)(v.HttpMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantId, _ = kitContext.Tenant(r.Context())
+	})))
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		status int
+		tenant string
+	}{
+		{"token tenant", tokenA, "", http.StatusOK, "a"},
+		{"same tenant", tokenA, "a", http.StatusOK, "a"},
+		{"another tenant", tokenA, "b", http.StatusForbidden, ""},
+		{"token without tenant", noTenant, "b", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenantId = ""
+			rq := httptest.NewRequest(http.MethodGet, "/", nil)
+			rq.Header.Set(HeaderAuthorization, "Bearer "+tt.token)
+			if tt.header != "" {
+				rq.Header.Set(kitHttp.HeaderTenantId, tt.header)
+			}
+			rs := httptest.NewRecorder()
+			h.ServeHTTP(rs, rq)
+			if rs.Code != tt.status || tenantId != tt.tenant {
+				t.Fatalf("unexpected status %d, tenant %s", rs.Code, tenantId)
+			}
+		})
+	}
+
+	// membership is confirmed by the service
+	v, _ = NewVerifier(&JwtConfig{Secret: "secret", TenantMembership: func(ctx context.Context, claims jwt.MapClaims, tenantId string) error {
+		if tenantId != "b" {
+			return errors.New("not a member")
+		}
+		return nil
+	}})
+	rCtx := kitContext.NewRequestCtx().WithNewRequestId()
+	if ctx, err := v.Authenticate(rCtx.WithTenant("b").ToContext(context.Background()), noTenant); err != nil {
+		t.Fatal(err)
+	} else if tid, _ := kitContext.Tenant(ctx); tid != "b" {
+		t.Fatalf("tenant expected, got %s", tid)
+	}
+	if _, err := v.Authenticate(rCtx.WithTenant("c").ToContext(context.Background()), noTenant); err == nil {
+		t.Fatal("membership must be checked")
+	}
+}
+
+func Test_PublicMethodTenant(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Public"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	rCtx := kitContext.NewRequestCtx().WithNewRequestId()
+
+	// tenant can't be verified without token, so it's rejected by default
+	v, _ := NewVerifier(&JwtConfig{Secret: "secret"})
+	interceptor := v.UnaryServerInterceptor(info.FullMethod)
+	if _, err := interceptor(rCtx.ToContext(context.Background()), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	_, err := interceptor(rCtx.WithTenant("a").ToContext(context.Background()), nil, info, handler)
+	if !errors.Is(err, er.Code(ErrCodeAuthTenantNotVerified)) {
+		t.Fatalf("%s expected, got %v", ErrCodeAuthTenantNotVerified, err)
+	}
+
+	v, _ = NewVerifier(&JwtConfig{Secret: "secret", PublicTenant: func(ctx context.Context, tenantId string) error {
+		if tenantId != "a" {
+			return errors.New("unknown tenant")
+		}
+		return nil
+	}})
+	interceptor = v.UnaryServerInterceptor(info.FullMethod)
+	if _, err := interceptor(rCtx.WithTenant("a").ToContext(context.Background()), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interceptor(rCtx.WithTenant("b").ToContext(context.Background()), nil, info, handler); err == nil {
+		t.Fatal("tenant must be validated")
+	}
+}
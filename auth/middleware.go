@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	kitHttp "git.jetbrains.space/orbi/fcsd/kit/http"
+	"github.com/gorilla/mux"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	HeaderAuthorization = "Authorization"
+	MdAuthorization     = "authorization"
+	bearerPrefix        = "bearer "
+)
+
+// bearerToken extracts token from "Bearer <token>" value
+func bearerToken(v string) string {
+	if len(v) > len(bearerPrefix) && strings.EqualFold(v[:len(bearerPrefix)], bearerPrefix) {
+		return strings.TrimSpace(v[len(bearerPrefix):])
+	}
+	return ""
+}
+
+// HttpMiddleware verifies bearer token from Authorization header and populates request context
+// invalid or missing token is responded with 401 by the controller, default controller is used if nil
+func (v *Verifier) HttpMiddleware(c *kitHttp.BaseController) mux.MiddlewareFunc {
+	if c == nil {
+		c = &kitHttp.BaseController{}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			token := bearerToken(r.Header.Get(HeaderAuthorization))
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				c.RespondError(w, ErrAuthNoToken(ctx))
+				return
+			}
+
+			ctx, err := v.Authenticate(ctx, token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				c.RespondError(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// grpcAuthenticate verifies token from incoming metadata
+func (v *Verifier) grpcAuthenticate(ctx context.Context) (context.Context, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(MdAuthorization); len(vals) > 0 {
+			token = bearerToken(vals[0])
+		}
+	}
+	if token == "" {
+		return nil, ErrAuthNoToken(ctx)
+	}
+	return v.Authenticate(ctx, token)
+}
+
+// publicTenant checks tenant requested on public method, it's accepted only by JwtConfig.PublicTenant
+func (v *Verifier) publicTenant(ctx context.Context) error {
+	tenantId, ok := kitContext.Tenant(ctx)
+	if !ok || tenantId == "" {
+		return nil
+	}
+	if v.cfg.PublicTenant == nil {
+		return ErrAuthTenantNotVerified(nil, ctx, tenantId)
+	}
+	if err := v.cfg.PublicTenant(ctx, tenantId); err != nil {
+		return ErrAuthTenantNotVerified(err, ctx, tenantId)
+	}
+	return nil
+}
+
+// UnaryServerInterceptor verifies bearer token from authorization metadata and populates request context
+// publicMethods (full method names, e.g. /grpc.health.v1.Health/Check) don't require token, tenant requested there is checked by JwtConfig.PublicTenant
+func (v *Verifier) UnaryServerInterceptor(publicMethods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if contains(publicMethods, info.FullMethod) {
+			if err := v.publicTenant(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+		ctx, err := v.grpcAuthenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is a stream counterpart of UnaryServerInterceptor
+func (v *Verifier) StreamServerInterceptor(publicMethods ...string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if contains(publicMethods, info.FullMethod) {
+			if err := v.publicTenant(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}
+		ctx, err := v.grpcAuthenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
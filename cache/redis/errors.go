@@ -1,11 +1,26 @@
 package redis
 
-import "git.jetbrains.space/orbi/fcsd/kit/er"
+import (
+	"context"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+)
 
 const (
-	ErrCodeRedisPingErr = "RDS-001"
+	ErrCodeRedisPingErr  = "RDS-001"
+	ErrCodeRedisNoTenant = "RDS-002"
 )
 
 var (
-	ErrRedisPingErr = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeRedisPingErr, "").Err() }
+	ErrRedisPingErr  = func(cause error) error { return er.WrapWithCode(cause, ErrCodeRedisPingErr).Err() }
+	ErrRedisNoTenant = func(ctx context.Context, key string) error {
+		return er.WithCode(ErrCodeRedisNoTenant).F(er.FF{"key": key}).C(ctx).Err()
+	}
 )
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeRedisPingErr, Message: "redis ping failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeRedisNoTenant, Message: "tenant isn't specified"},
+	)
+}
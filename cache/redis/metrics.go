@@ -0,0 +1,36 @@
+package redis
+
+import (
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"github.com/go-redis/redis"
+)
+
+// instrument measures command durations, pipelines are measured as a whole
+func instrument(client *redis.Client) {
+	client.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			start := time.Now()
+			err := old(cmd)
+			observe(cmd.Name(), start, err)
+			return err
+		}
+	})
+	client.WrapProcessPipeline(func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			start := time.Now()
+			err := old(cmds)
+			observe("pipeline", start, err)
+			return err
+		}
+	})
+}
+
+func observe(command string, start time.Time, err error) {
+	// missing key isn't a failure
+	if err == redis.Nil {
+		err = nil
+	}
+	metrics.RedisCommandDuration.WithLabelValues(command, metrics.Status(err)).Observe(time.Since(start).Seconds())
+}
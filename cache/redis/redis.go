@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"fmt"
 	"git.jetbrains.space/orbi/fcsd/kit/log"
 	"github.com/go-redis/redis"
@@ -11,6 +12,7 @@ type Redis struct {
 	Instance *redis.Client
 	Ttl      time.Duration
 	logger   log.CLoggerFunc
+	tracing  bool
 }
 
 // Config redis config
@@ -19,6 +21,10 @@ type Config struct {
 	Port     string
 	Password string
 	Ttl      uint
+	// Metrics - if true, command durations are collected
+	Metrics bool
+	// Tracing - if true, clients returned by WithContext create a span per command (see tracing.Init)
+	Tracing bool
 }
 
 func Open(params *Config, logger log.CLoggerFunc) (*Redis, error) {
@@ -30,6 +36,9 @@ func Open(params *Config, logger log.CLoggerFunc) (*Redis, error) {
 		Password: params.Password,
 		DB:       0,
 	})
+	if params.Metrics {
+		instrument(client)
+	}
 	_, err := client.Ping().Result()
 	if err != nil {
 		return nil, ErrRedisPingErr(err)
@@ -40,9 +49,20 @@ func Open(params *Config, logger log.CLoggerFunc) (*Redis, error) {
 		Instance: client,
 		Ttl:      time.Duration(params.Ttl) * time.Second,
 		logger:   logger,
+		tracing:  params.Tracing,
 	}, nil
 }
 
+// WithContext returns client bound to the context
+// if tracing is enabled, commands are traced as children of the span found in the context
+func (r *Redis) WithContext(ctx context.Context) *redis.Client {
+	c := r.Instance.WithContext(ctx)
+	if r.tracing {
+		traceClient(ctx, c)
+	}
+	return c
+}
+
 func (r *Redis) Close() {
 	if r.Instance != nil {
 		_ = r.Instance.Close()
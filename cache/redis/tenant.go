@@ -0,0 +1,17 @@
+package redis
+
+import (
+	"context"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+)
+
+// TenantKey prefixes key with tenant id of the request context ("<tenant>:<key>"), so that tenants don't share cached data
+// it fails if there is no tenant
+func TenantKey(ctx context.Context, key string) (string, error) {
+	tenantId, ok := kitContext.Tenant(ctx)
+	if !ok {
+		return "", ErrRedisNoTenant(ctx, key)
+	}
+	return tenantId + ":" + key, nil
+}
@@ -0,0 +1,22 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+)
+
+func Test_TenantKey(t *testing.T) {
+	keyA, err := TenantKey(kitContext.NewRequestCtx().WithTenant("a").ToContext(context.Background()), "users:1")
+	if err != nil || keyA != "a:users:1" {
+		t.Fatalf("unexpected key %s %v", keyA, err)
+	}
+	keyB, _ := TenantKey(kitContext.NewRequestCtx().WithTenant("b").ToContext(context.Background()), "users:1")
+	if keyA == keyB {
+		t.Fatal("tenants mustn't share keys")
+	}
+	if _, err := TenantKey(context.Background(), "users:1"); err == nil {
+		t.Fatal("key without tenant mustn't be built")
+	}
+}
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"context"
+
+	"git.jetbrains.space/orbi/fcsd/kit/tracing"
+	"github.com/go-redis/redis"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceClient creates a span per command, pipelines are traced as a whole
+func traceClient(ctx context.Context, client *redis.Client) {
+	client.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			_, span := startSpan(ctx, cmd.Name())
+			err := old(cmd)
+			endSpan(span, err)
+			return err
+		}
+	})
+	client.WrapProcessPipeline(func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			_, span := startSpan(ctx, "pipeline")
+			err := old(cmds)
+			endSpan(span, err)
+			return err
+		}
+	})
+}
+
+func startSpan(ctx context.Context, command string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "redis "+command,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperationKey.String(command)))
+}
+
+func endSpan(span trace.Span, err error) {
+	// missing key isn't a failure
+	if err == redis.Nil {
+		err = nil
+	}
+	tracing.End(span, err)
+}
@@ -11,6 +11,12 @@ const (
 
 var (
 	ErrBaseModelCannotPublishToQueue = func(ctx context.Context, topic string) error {
-		return er.WithBuilder(ErrCodeBaseModelCannotPublishToQueue, "cannot publish to topic").C(ctx).F(er.FF{"topic": topic}).Err()
+		return er.WithCode(ErrCodeBaseModelCannotPublishToQueue).C(ctx).F(er.FF{"topic": topic}).Err()
 	}
 )
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeBaseModelCannotPublishToQueue, Message: "cannot publish to topic"},
+	)
+}
@@ -0,0 +1,79 @@
+package common
+
+// filter operators
+const (
+	FilterOpEq   = "eq"
+	FilterOpNe   = "ne"
+	FilterOpGt   = "gt"
+	FilterOpGte  = "gte"
+	FilterOpLt   = "lt"
+	FilterOpLte  = "lte"
+	FilterOpIn   = "in"
+	FilterOpLike = "like"
+)
+
+// filter field types
+const (
+	FilterTypeString = "string"
+	FilterTypeInt    = "int"
+	FilterTypeFloat  = "float"
+	FilterTypeBool   = "bool"
+	FilterTypeTime   = "time"
+)
+
+// FilterExpr is a node of filter AST
+// query params are combined with FilterAnd only, there is no syntax for disjunction
+type FilterExpr interface {
+	filterExpr()
+}
+
+// FilterAnd is satisfied if all expressions are satisfied
+type FilterAnd struct {
+	Exprs []FilterExpr
+}
+
+// FilterCondition compares field with values
+// Values are typed according to the field type (string, int64, float64, bool, time.Time)
+// all operators except FilterOpIn have exactly one value
+type FilterCondition struct {
+	Field  string
+	Op     string
+	Values []interface{}
+}
+
+func (*FilterAnd) filterExpr()       {}
+func (*FilterCondition) filterExpr() {}
+
+// FilterField describes a field allowed for filtering
+type FilterField struct {
+	// Type - field type, values are converted to
+	Type string
+	// Ops - allowed operators, if empty all operators supported by the type are allowed
+	Ops []string
+}
+
+// FilterFields is a whitelist of fields allowed for filtering keyed by field name
+type FilterFields map[string]*FilterField
+
+// FilterTypeOps specifies operators supported by field types
+var FilterTypeOps = map[string][]string{
+	FilterTypeString: {FilterOpEq, FilterOpNe, FilterOpIn, FilterOpLike},
+	FilterTypeInt:    {FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte, FilterOpIn},
+	FilterTypeFloat:  {FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte, FilterOpIn},
+	FilterTypeBool:   {FilterOpEq, FilterOpNe},
+	FilterTypeTime:   {FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte},
+}
+
+// Allows checks if operator is allowed for the field
+func (f *FilterField) Allows(op string) bool {
+	ops := f.Ops
+	if len(ops) == 0 {
+		ops = FilterTypeOps[f.Type]
+	}
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
@@ -1,17 +1,30 @@
 package common
 
+const (
+	SortAsc  = "asc"  // SortAsc - ascending sort direction
+	SortDesc = "desc" // SortDesc - descending sort direction
+)
+
 type SortRequest struct {
 	Field     string
 	Direction string
 }
 
+// PagingRequest requests a page of items
+// for offset pagination Index is a page number starting from 1
+// for keyset pagination Cursor is a token returned in PagingResponse.NextCursor of the previous page (empty for the first page)
 type PagingRequest struct {
 	Size   int
 	Index  int
 	SortBy []*SortRequest
+	Cursor string
 }
 
+// PagingResponse describes a returned page
+// Total is populated for offset pagination, NextCursor - for keyset pagination (empty if there are no more items)
 type PagingResponse struct {
-	Total int
-	Index int
-}
\ No newline at end of file
+	Total      int
+	Index      int
+	Size       int
+	NextCursor string
+}
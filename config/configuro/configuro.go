@@ -7,9 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/go-playground/locales/en"
+	"git.jetbrains.space/orbi/fcsd/kit/validation"
 	ut "github.com/go-playground/universal-translator"
-	ens "github.com/go-playground/validator/translations/en"
 	"github.com/joho/godotenv"
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
@@ -323,12 +322,8 @@ func (c *Config) setConfigFilepath(path string) error {
 }
 
 func (c *Config) enableValidateUsingTag() {
-	c.validator = validator.New()
-	c.validator.SetTagName(c.validateTag)
 	// Get English Errors
-	uni := ut.New(en.New(), en.New())
-	c.validatorTrans, _ = uni.GetTranslator("en")
-	_ = ens.RegisterDefaultTranslations(c.validator, c.validatorTrans)
+	c.validator, c.validatorTrans = validation.NewValidator(c.validateTag)
 }
 
 func (c *Config) addDecoderConfig() {
@@ -4,23 +4,43 @@ import (
 	errors "git.jetbrains.space/orbi/fcsd/kit/er"
 )
 
+const (
+	ErrCodeConfigTargetObjectInvalidType = "CFG-001"
+	ErrCodeConfigPathIsEmpty             = "CFG-002"
+	ErrCodeConfigFileNotFound            = "CFG-003"
+	ErrCodeConfigFileOpen                = "CFG-004"
+	ErrCodeConfigInit                    = "CFG-005"
+	ErrCodeConfigLoad                    = "CFG-006"
+)
+
 var (
 	ErrConfigTargetObjectInvalidType = func() error {
-		return errors.WithBuilder("CFG-001", "config target is not correct format").Err()
+		return errors.WithCode(ErrCodeConfigTargetObjectInvalidType).Err()
 	}
 	ErrConfigPaErrConfigPathIsEmpty = func() error {
-		return errors.WithBuilder("CFG-002", "config path is empty").Err()
+		return errors.WithCode(ErrCodeConfigPathIsEmpty).Err()
 	}
 	ErrConfigFileNotFound = func(v string) error {
-		return errors.WithBuilder("CFG-003", "config file on path %s is not found", v).Err()
+		return errors.WithCode(ErrCodeConfigFileNotFound, v).Err()
 	}
 	ErrConfigFileOpen = func(err error, v string) error {
-		return errors.WrapWithBuilder(err, "CFG-004", "open file: %s", v).Err()
+		return errors.WrapWithCode(err, ErrCodeConfigFileOpen, v).Err()
 	}
 	ErrConfigInit = func(err error) error {
-		return errors.WrapWithBuilder(err, "CFG-005", "can not init config").Err()
+		return errors.WrapWithCode(err, ErrCodeConfigInit).Err()
 	}
 	ErrConfigLoad = func(err error) error {
-		return errors.WrapWithBuilder(err, "CFG-006", "can not load config").Err()
+		return errors.WrapWithCode(err, ErrCodeConfigLoad).Err()
 	}
 )
+
+func init() {
+	errors.Register(
+		&errors.CodeDef{Code: ErrCodeConfigTargetObjectInvalidType, Message: "config target is not correct format", Severity: errors.SeverityCritical},
+		&errors.CodeDef{Code: ErrCodeConfigPathIsEmpty, Message: "config path is empty", Severity: errors.SeverityCritical},
+		&errors.CodeDef{Code: ErrCodeConfigFileNotFound, Message: "config file on path %s is not found", Severity: errors.SeverityCritical},
+		&errors.CodeDef{Code: ErrCodeConfigFileOpen, Message: "open file: %s", Severity: errors.SeverityCritical},
+		&errors.CodeDef{Code: ErrCodeConfigInit, Message: "can not init config", Severity: errors.SeverityCritical},
+		&errors.CodeDef{Code: ErrCodeConfigLoad, Message: "can not load config", Severity: errors.SeverityCritical},
+	)
+}
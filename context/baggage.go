@@ -0,0 +1,238 @@
+package context
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// baggage sinks, each key is propagated only to sinks allowed by the policy
+const (
+	BaggageSinkLog   = "log"   // BaggageSinkLog - logs and error context (ToMap)
+	BaggageSinkGrpc  = "grpc"  // BaggageSinkGrpc - outgoing gRPC metadata
+	BaggageSinkQueue = "queue" // BaggageSinkQueue - queue messages
+)
+
+const (
+	DefaultBaggageMaxEntries = 32
+	DefaultBaggageMaxSize    = 4096
+)
+
+// BaggageConfig is an allow-list of baggage keys and limits
+type BaggageConfig struct {
+	// MaxEntries - max number of entries, DefaultBaggageMaxEntries if empty
+	MaxEntries int `config:"max-entries"`
+	// MaxSize - max total size of keys and values in bytes, DefaultBaggageMaxSize if empty
+	MaxSize int `config:"max-size"`
+	// Keys - allowed keys, baggage with other keys is available within the process only
+	Keys []*BaggageKeyConfig `config:"keys"`
+}
+
+// BaggageKeyConfig specifies sinks the key is propagated to
+// key ending with "*" matches all keys with the prefix (e.g. "feature.*")
+type BaggageKeyConfig struct {
+	Key   string `config:"key"`
+	Log   bool   `config:"log"`
+	Grpc  bool   `config:"grpc"`
+	Queue bool   `config:"queue"`
+}
+
+var (
+	baggageMu     sync.RWMutex
+	baggageConfig = &BaggageConfig{}
+)
+
+// SetBaggageConfig sets baggage allow-list and limits, normally it's called once on start
+func SetBaggageConfig(cfg *BaggageConfig) {
+	if cfg == nil {
+		cfg = &BaggageConfig{}
+	}
+	baggageMu.Lock()
+	defer baggageMu.Unlock()
+	baggageConfig = cfg
+}
+
+func getBaggageConfig() *BaggageConfig {
+	baggageMu.RLock()
+	defer baggageMu.RUnlock()
+	return baggageConfig
+}
+
+func (c *BaggageConfig) maxEntries() int {
+	if c.MaxEntries > 0 {
+		return c.MaxEntries
+	}
+	return DefaultBaggageMaxEntries
+}
+
+func (c *BaggageConfig) maxSize() int {
+	if c.MaxSize > 0 {
+		return c.MaxSize
+	}
+	return DefaultBaggageMaxSize
+}
+
+// allowed checks if the key is propagated to the sink
+func (c *BaggageConfig) allowed(key, sink string) bool {
+	for _, k := range c.Keys {
+		match := k.Key == key || (strings.HasSuffix(k.Key, "*") && strings.HasPrefix(key, strings.TrimSuffix(k.Key, "*")))
+		if !match {
+			continue
+		}
+		switch sink {
+		case BaggageSinkLog:
+			return k.Log
+		case BaggageSinkGrpc:
+			return k.Grpc
+		case BaggageSinkQueue:
+			return k.Queue
+		}
+	}
+	return false
+}
+
+// filterBaggage returns baggage entries allowed for the sink, nil if none
+func filterBaggage(baggage map[string]string, sink string) map[string]string {
+	if len(baggage) == 0 {
+		return nil
+	}
+	cfg := getBaggageConfig()
+	var res map[string]string
+	for k, v := range baggage {
+		if cfg.allowed(k, sink) {
+			if res == nil {
+				res = make(map[string]string)
+			}
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// limitBaggage drops entries exceeding limits, it's applied to baggage received from transports
+// entries are taken in order of keys, so that the same entries are kept for the same baggage
+func limitBaggage(baggage map[string]string) map[string]string {
+	if len(baggage) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(baggage))
+	for k := range baggage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	cfg := getBaggageConfig()
+	res := make(map[string]string, len(baggage))
+	size := 0
+	for _, k := range keys {
+		v := baggage[k]
+		if len(res) >= cfg.maxEntries() || size+len(k)+len(v) > cfg.maxSize() {
+			continue
+		}
+		res[k] = v
+		size += len(k) + len(v)
+	}
+	return res
+}
+
+func baggageSize(baggage map[string]string) int {
+	size := 0
+	for k, v := range baggage {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// WithBaggage sets baggage entry
+// entry is ignored if it exceeds limits (see BaggageConfig)
+func (r *RequestContext) WithBaggage(key, value string) *RequestContext {
+	if key == "" {
+		return r
+	}
+	cfg := getBaggageConfig()
+	old, exists := r.Baggage[key]
+	size := baggageSize(r.Baggage) + len(key) + len(value)
+	if exists {
+		size -= len(key) + len(old)
+	} else if len(r.Baggage) >= cfg.maxEntries() {
+		return r
+	}
+	if size > cfg.maxSize() {
+		return r
+	}
+	// baggage is shared among copies, so it's replaced rather than modified
+	cp := r.clone()
+	cp.Baggage = make(map[string]string, len(r.Baggage)+1)
+	for k, v := range r.Baggage {
+		cp.Baggage[k] = v
+	}
+	cp.Baggage[key] = value
+	return cp
+}
+
+func (r *RequestContext) WithBaggageInt(key string, value int64) *RequestContext {
+	return r.WithBaggage(key, strconv.FormatInt(value, 10))
+}
+
+func (r *RequestContext) WithBaggageBool(key string, value bool) *RequestContext {
+	return r.WithBaggage(key, strconv.FormatBool(value))
+}
+
+// GetBaggage returns baggage entry
+func (r *RequestContext) GetBaggage(key string) (string, bool) {
+	v, ok := r.Baggage[key]
+	return v, ok
+}
+
+// GetBaggageInt returns baggage entry as int, false if it's missing or not int
+func (r *RequestContext) GetBaggageInt(key string) (int64, bool) {
+	v, ok := r.Baggage[key]
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	return i, err == nil
+}
+
+// GetBaggageBool returns baggage entry as bool, false if it's missing or not bool
+func (r *RequestContext) GetBaggageBool(key string) (bool, bool) {
+	v, ok := r.Baggage[key]
+	if !ok {
+		return false, false
+	}
+	b, err := strconv.ParseBool(v)
+	return b, err == nil
+}
+
+// GetBaggageAll returns a copy of all baggage entries
+func (r *RequestContext) GetBaggageAll() map[string]string {
+	res := make(map[string]string, len(r.Baggage))
+	for k, v := range r.Baggage {
+		res[k] = v
+	}
+	return res
+}
+
+// LogBaggage returns baggage entries allowed to be logged
+func (r *RequestContext) LogBaggage() map[string]string {
+	return filterBaggage(r.Baggage, BaggageSinkLog)
+}
+
+// ForSink returns a copy of request context with baggage allowed for the sink (BaggageSinkGrpc, BaggageSinkQueue)
+func (r *RequestContext) ForSink(sink string) *RequestContext {
+	cp := r.clone()
+	cp.Baggage = filterBaggage(r.Baggage, sink)
+	return cp
+}
+
+// FromSink returns a copy of request context received from the sink with allow-list and limits applied
+// invalid tenant id is dropped
+func (r *RequestContext) FromSink(sink string) *RequestContext {
+	cp := r.clone()
+	cp.Baggage = limitBaggage(filterBaggage(r.Baggage, sink))
+	if cp.Tid != "" && !IsValidTenantId(cp.Tid) {
+		cp.Tid = ""
+	}
+	return cp
+}
@@ -0,0 +1,78 @@
+package context
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func Test_BaggagePropagation(t *testing.T) {
+	SetBaggageConfig(&BaggageConfig{Keys: []*BaggageKeyConfig{
+		{Key: "tenant", Log: true, Grpc: true, Queue: true},
+		{Key: "feature.*", Grpc: true},
+	}})
+	defer SetBaggageConfig(nil)
+
+	r := NewRequestCtx().WithNewRequestId().
+		WithBaggage("tenant", "t1").
+		WithBaggageBool("feature.x", true).
+		WithBaggageInt("local", 42)
+
+	if v, ok := r.GetBaggageInt("local"); !ok || v != 42 {
+		t.Fatalf("unexpected local value %v", v)
+	}
+
+	md, _ := FromContextToGrpcMD(r.ToContext(context.Background()))
+	remote, ok := Request(FromGrpcMD(context.Background(), md))
+	if !ok {
+		t.Fatal("request context expected")
+	}
+	if v, _ := remote.GetBaggage("tenant"); v != "t1" {
+		t.Fatal("tenant must be propagated")
+	}
+	if v, ok := remote.GetBaggageBool("feature.x"); !ok || !v {
+		t.Fatal("feature must be propagated")
+	}
+	if _, ok := remote.GetBaggage("local"); ok {
+		t.Fatal("local value mustn't be propagated")
+	}
+
+	if log := r.LogBaggage(); len(log) != 1 || log["tenant"] != "t1" {
+		t.Fatalf("unexpected logged baggage %v", log)
+	}
+
+	ctx, err := FromMap(context.Background(), r.ToMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromMap, _ := Request(ctx); fromMap.GetRequestId() != r.GetRequestId() || fromMap.Baggage["tenant"] != "t1" {
+		t.Fatalf("unexpected request context %+v", fromMap)
+	}
+}
+
+func Test_BaggageLimits(t *testing.T) {
+	SetBaggageConfig(&BaggageConfig{MaxEntries: 2, MaxSize: 10})
+	defer SetBaggageConfig(nil)
+
+	r := NewRequestCtx().WithBaggage("a", "1").WithBaggage("b", strings.Repeat("x", 20)).WithBaggage("c", "3").WithBaggage("d", "4")
+	if len(r.Baggage) != 2 || r.Baggage["b"] != "" {
+		t.Fatalf("unexpected baggage %v", r.Baggage)
+	}
+	// replacing existing entry is allowed
+	if r2 := r.WithBaggage("a", "2"); r2.Baggage["a"] != "2" || r.Baggage["a"] != "1" {
+		t.Fatal("entry must be replaced in the copy only")
+	}
+}
+
+func Test_LimitBaggageDeterministic(t *testing.T) {
+	SetBaggageConfig(&BaggageConfig{MaxEntries: 2, Keys: []*BaggageKeyConfig{{Key: "*", Queue: true}}})
+	defer SetBaggageConfig(nil)
+
+	r := &RequestContext{Baggage: map[string]string{"d": "4", "b": "2", "c": "3", "a": "1"}}
+	for i := 0; i < 10; i++ {
+		res := r.FromSink(BaggageSinkQueue)
+		if len(res.Baggage) != 2 || res.Baggage["a"] != "1" || res.Baggage["b"] != "2" {
+			t.Fatalf("unexpected baggage %v", res.Baggage)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package context
 import (
 	"context"
 	"encoding/json"
+	"regexp"
 
 	"git.jetbrains.space/orbi/fcsd/kit/utils"
 	"github.com/mitchellh/mapstructure"
@@ -19,6 +20,9 @@ const (
 	CLIENT_TYPE_WEBRTC = "webrtc"
 )
 
+// RequestContext is immutable, builders (With..., Rest, Client etc.) return a modified copy
+// and ToContext stores a snapshot, so a context can be safely shared among goroutines
+// fields must not be changed in place, Roles and Baggage are shared among copies
 type RequestContext struct {
 	// request ID
 	Rid string `json:"_ctx.rid"`
@@ -34,10 +38,28 @@ type RequestContext struct {
 	Cid string `json:"_ctx.cid"`
 	// client type
 	Cl string `json:"_ctx.cl"`
+	// tenant ID
+	Tid string `json:"_ctx.tid,omitempty"`
+	// parent request ID, set for child contexts (see WithChild)
+	Prid string `json:"_ctx.prid,omitempty"`
+	// root request ID, the request id of the first context in the chain (see WithChild)
+	Rrid string `json:"_ctx.rrid,omitempty"`
+	// user's roles
+	Roles []string `json:"_ctx.roles,omitempty"`
+	// custom key/value entries, propagation is controlled by BaggageConfig
+	Baggage map[string]string `json:"_ctx.bg,omitempty"`
 }
 
 type requestContextKey struct{}
 
+// validTenantId restricts tenant ids, as they are used in DB schema names, cache keys and queue topics
+var validTenantId = regexp.MustCompile(`^[a-zA-Z0-9_\-]{1,63}$`)
+
+// IsValidTenantId checks if tenant id is allowed
+func IsValidTenantId(tenantId string) bool {
+	return validTenantId.MatchString(tenantId)
+}
+
 func NewRequestCtx() *RequestContext {
 	return &RequestContext{}
 }
@@ -70,6 +92,14 @@ func (r *RequestContext) GetUsername() string {
 	return r.Un
 }
 
+func (r *RequestContext) GetTenantId() string {
+	return r.Tid
+}
+
+func (r *RequestContext) GetRoles() []string {
+	return r.Roles
+}
+
 func (r *RequestContext) Empty() *RequestContext {
 
 	return &RequestContext{
@@ -84,99 +114,195 @@ func (r *RequestContext) Empty() *RequestContext {
 }
 
 func (r *RequestContext) WithRequestId(requestId string) *RequestContext {
-	r.Rid = requestId
-	return r
+	cp := r.clone()
+	cp.Rid = requestId
+	return cp
 }
 
 func (r *RequestContext) WithNewRequestId() *RequestContext {
-	r.Rid = utils.NewId()
-	return r
+	cp := r.clone()
+	cp.Rid = utils.NewId()
+	return cp
 }
 
 func (r *RequestContext) WithSessionId(sessionId string) *RequestContext {
-	r.Sid = sessionId
-	return r
+	cp := r.clone()
+	cp.Sid = sessionId
+	return cp
 }
 
 func (r *RequestContext) WithChatUserId(chatUserId string) *RequestContext {
-	r.Cid = chatUserId
-	return r
+	cp := r.clone()
+	cp.Cid = chatUserId
+	return cp
 }
 
 func (r *RequestContext) Rest() *RequestContext {
-	r.Cl = CLIENT_TYPE_REST
-	return r
+	cp := r.clone()
+	cp.Cl = CLIENT_TYPE_REST
+	return cp
 }
 
 func (r *RequestContext) Webrtc() *RequestContext {
-	r.Cl = CLIENT_TYPE_WEBRTC
-	return r
+	cp := r.clone()
+	cp.Cl = CLIENT_TYPE_WEBRTC
+	return cp
 }
 
 func (r *RequestContext) Test() *RequestContext {
-	r.Cl = CLIENT_TYPE_TEST
-	return r
+	cp := r.clone()
+	cp.Cl = CLIENT_TYPE_TEST
+	return cp
 }
 
 func (r *RequestContext) Job() *RequestContext {
-	r.Cl = CLIENT_TYPE_JOB
-	return r
+	cp := r.clone()
+	cp.Cl = CLIENT_TYPE_JOB
+	return cp
 }
 
 func (r *RequestContext) Queue() *RequestContext {
-	r.Cl = CLIENT_TYPE_QUEUE
-	return r
+	cp := r.clone()
+	cp.Cl = CLIENT_TYPE_QUEUE
+	return cp
 }
 
 func (r *RequestContext) Ws() *RequestContext {
-	r.Cl = CLIENT_TYPE_WS
-	return r
+	cp := r.clone()
+	cp.Cl = CLIENT_TYPE_WS
+	return cp
 }
 
 func (r *RequestContext) Client(client string) *RequestContext {
-	r.Cl = client
-	return r
+	cp := r.clone()
+	cp.Cl = client
+	return cp
 }
 
 func (r *RequestContext) WithUser(userId, username string) *RequestContext {
-	r.Uid = userId
-	r.Un = username
-	return r
+	cp := r.clone()
+	cp.Uid = userId
+	cp.Un = username
+	return cp
 }
 
 func (r *RequestContext) WithUserSession(sessionId string) *RequestContext {
-	r.Usid = sessionId
-	return r
+	cp := r.clone()
+	cp.Usid = sessionId
+	return cp
+}
+
+func (r *RequestContext) WithTenant(tenantId string) *RequestContext {
+	cp := r.clone()
+	cp.Tid = tenantId
+	return cp
 }
 
+func (r *RequestContext) WithRoles(roles ...string) *RequestContext {
+	cp := r.clone()
+	cp.Roles = append([]string(nil), roles...)
+	return cp
+}
+
+// WithoutIdentity returns a copy without user, sessions, chat user, roles and tenant
+// it's used to populate identity from a trusted source only (e.g. verified token)
+func (r *RequestContext) WithoutIdentity() *RequestContext {
+	cp := r.clone()
+	cp.Uid, cp.Un, cp.Usid, cp.Sid, cp.Cid, cp.Tid = "", "", "", "", "", ""
+	cp.Roles = nil
+	return cp
+}
+
+// clone makes a shallow copy, Roles and Baggage are shared as builders replace them rather than modify
+func (r *RequestContext) clone() *RequestContext {
+	if r == nil {
+		return &RequestContext{}
+	}
+	cp := *r
+	return &cp
+}
+
+// Child returns a copy with a new request id, the current request id becomes the parent one
+func (r *RequestContext) Child() *RequestContext {
+	cp := r.clone()
+	cp.Prid = r.Rid
+	if r.Rrid != "" {
+		cp.Rrid = r.Rrid
+	} else {
+		cp.Rrid = r.Rid
+	}
+	cp.Rid = utils.NewId()
+	return cp
+}
+
+func (r *RequestContext) GetParentRequestId() string {
+	return r.Prid
+}
+
+// GetRootRequestId returns id of the first request in the chain, the request id if the context isn't a child
+func (r *RequestContext) GetRootRequestId() string {
+	if r.Rrid != "" {
+		return r.Rrid
+	}
+	return r.Rid
+}
+
+// ToContext puts a snapshot of request context to the context
 func (r *RequestContext) ToContext(parent context.Context) context.Context {
 	if parent == nil {
 		parent = context.Background()
 	}
-	return context.WithValue(parent, requestContextKey{}, r)
+	var snapshot *RequestContext
+	if r != nil {
+		snapshot = r.clone()
+	}
+	return context.WithValue(parent, requestContextKey{}, snapshot)
+}
+
+// WithChild returns context with a child request context (see Child)
+// if there is no request context, a new one is created
+func WithChild(ctx context.Context) context.Context {
+	r, ok := Request(ctx)
+	if !ok {
+		return NewRequestCtx().WithNewRequestId().ToContext(ctx)
+	}
+	return r.Child().ToContext(ctx)
 }
 
 func (r *RequestContext) ToMap() map[string]interface{} {
 	return map[string]interface{}{
-		"_ctx.rid":  r.Rid,
-		"_ctx.sid":  r.Sid,
-		"_ctx.uid":  r.Uid,
-		"_ctx.usid": r.Usid,
-		"_ctx.un":   r.Un,
-		"_ctx.cid":  r.Cid,
-		"_ctx.cl":   r.Cl,
+		"_ctx.rid":   r.Rid,
+		"_ctx.sid":   r.Sid,
+		"_ctx.uid":   r.Uid,
+		"_ctx.usid":  r.Usid,
+		"_ctx.un":    r.Un,
+		"_ctx.cid":   r.Cid,
+		"_ctx.cl":    r.Cl,
+		"_ctx.tid":   r.Tid,
+		"_ctx.prid":  r.Prid,
+		"_ctx.rrid":  r.Rrid,
+		"_ctx.roles": r.Roles,
+		"_ctx.bg":    r.LogBaggage(),
 	}
 }
 
+// Request returns a copy of request context stored in the context
+// if there is no request context, an empty one is returned, it isn't stored in the context
 func Request(context context.Context) (*RequestContext, bool) {
-	if r, ok := context.Value(requestContextKey{}).(*RequestContext); ok {
-		return r, true
+	if r, ok := context.Value(requestContextKey{}).(*RequestContext); ok && r != nil {
+		return r.clone(), true
 	}
 	return &RequestContext{}, false
 }
 
+// Tenant returns tenant id of the request context stored in the context, false if there is no tenant
+func Tenant(context context.Context) (string, bool) {
+	r, _ := Request(context)
+	return r.GetTenantId(), r.GetTenantId() != ""
+}
+
 func MustRequest(context context.Context) (*RequestContext, error) {
-	if r, ok := context.Value(requestContextKey{}).(*RequestContext); ok {
+	if r, ok := Request(context); ok {
 		return r, nil
 	}
 	return &RequestContext{}, errors.New("context is invalid")
@@ -184,10 +310,20 @@ func MustRequest(context context.Context) (*RequestContext, error) {
 
 func FromMap(ctx context.Context, mp map[string]interface{}) (context.Context, error) {
 	var r *RequestContext
-	err := mapstructure.Decode(mp, &r)
+	// keys are the same as produced by ToMap
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{TagName: "json", Result: &r})
 	if err != nil {
 		return nil, err
 	}
+	if err := dec.Decode(mp); err != nil {
+		return nil, err
+	}
+	if r != nil {
+		r.Baggage = limitBaggage(r.Baggage)
+		if r.Tid != "" && !IsValidTenantId(r.Tid) {
+			r.Tid = ""
+		}
+	}
 	return r.ToContext(ctx), nil
 }
 
@@ -198,7 +334,7 @@ func FromGrpcMD(ctx context.Context, md metadata.MD) context.Context {
 			rm := []byte(rqb[0])
 			rq := &RequestContext{}
 			_ = json.Unmarshal(rm, rq)
-			return context.WithValue(ctx, requestContextKey{}, rq)
+			return rq.FromSink(BaggageSinkGrpc).ToContext(ctx)
 		}
 	}
 	return ctx
@@ -206,7 +342,7 @@ func FromGrpcMD(ctx context.Context, md metadata.MD) context.Context {
 
 func FromContextToGrpcMD(ctx context.Context) (metadata.MD, bool) {
 	if r, ok := Request(ctx); ok {
-		rm, _ := json.Marshal(*r)
+		rm, _ := json.Marshal(r.ForSink(BaggageSinkGrpc))
 		return metadata.Pairs("rq-bin", string(rm)), true
 	}
 	return metadata.Pairs(), false
@@ -0,0 +1,67 @@
+package context
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func Test_Immutable(t *testing.T) {
+	r := NewRequestCtx().WithRequestId("rid").WithRoles("admin")
+	ctx := r.ToContext(context.Background())
+
+	// neither builders nor modifications of the returned copy affect the stored snapshot
+	r2 := r.WithUser("uid", "un")
+	stored, _ := Request(ctx)
+	stored.Uid = "changed"
+	if r.Uid != "" || r2.Uid != "uid" {
+		t.Fatal("builder must return a copy")
+	}
+	if stored, _ := Request(ctx); stored.Uid != "" {
+		t.Fatal("snapshot mustn't be changed")
+	}
+}
+
+func Test_WithChild(t *testing.T) {
+	ctx := NewRequestCtx().WithRequestId("root").ToContext(context.Background())
+
+	child := WithChild(ctx)
+	grandChild := WithChild(child)
+
+	c, _ := Request(child)
+	gc, _ := Request(grandChild)
+	if c.GetParentRequestId() != "root" || c.GetRootRequestId() != "root" || c.GetRequestId() == "root" {
+		t.Fatalf("unexpected child %+v", c)
+	}
+	if gc.GetParentRequestId() != c.GetRequestId() || gc.GetRootRequestId() != "root" {
+		t.Fatalf("unexpected grand child %+v", gc)
+	}
+}
+
+// Test_ConcurrentPropagation must be run with -race
+func Test_ConcurrentPropagation(t *testing.T) {
+	ctx := NewRequestCtx().WithNewRequestId().WithBaggage("k", "v").ToContext(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, _ := Request(ctx)
+			r = r.WithUser("uid", "un").WithBaggage("k2", "v2").WithRoles("role")
+			childCtx := WithChild(r.ToContext(ctx))
+
+			md, _ := FromContextToGrpcMD(childCtx)
+			remote, ok := Request(FromGrpcMD(context.Background(), md))
+			if !ok || remote.GetUserId() != "uid" {
+				t.Error("request context isn't propagated")
+			}
+			_ = remote.ToMap()
+		}()
+	}
+	wg.Wait()
+
+	if r, _ := Request(ctx); r.GetUserId() != "" || len(r.Baggage) != 1 {
+		t.Fatalf("shared request context is modified %+v", r)
+	}
+}
@@ -0,0 +1,60 @@
+package context
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// PeerIdentity is an identity of the client verified by mTLS
+// unlike RequestContext it describes the direct peer only, so it isn't propagated to downstream calls
+type PeerIdentity struct {
+	// CommonName - subject common name
+	CommonName string
+	// Organization - subject organizations
+	Organization []string
+	// DnsNames - DNS subject alternative names
+	DnsNames []string
+	// Uris - URI subject alternative names (e.g. SPIFFE ID)
+	Uris []string
+	// SerialNumber - certificate serial number
+	SerialNumber string
+}
+
+type peerIdentityKey struct{}
+
+// PeerIdentityFromCert builds identity from the client certificate
+func PeerIdentityFromCert(cert *x509.Certificate) *PeerIdentity {
+	p := &PeerIdentity{
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DnsNames:     cert.DNSNames,
+		SerialNumber: cert.SerialNumber.String(),
+	}
+	for _, u := range cert.URIs {
+		p.Uris = append(p.Uris, u.String())
+	}
+	return p
+}
+
+// PeerIdentityFromTls builds identity from the verified client certificate of TLS connection
+// returns false if client certificate isn't given or verified
+func PeerIdentityFromTls(state *tls.ConnectionState) (*PeerIdentity, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return PeerIdentityFromCert(state.VerifiedChains[0][0]), true
+}
+
+func (p *PeerIdentity) ToContext(parent context.Context) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, peerIdentityKey{}, p)
+}
+
+// Peer returns verified client identity if any
+func Peer(ctx context.Context) (*PeerIdentity, bool) {
+	p, ok := ctx.Value(peerIdentityKey{}).(*PeerIdentity)
+	return p, ok
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	fieldCreatedBy = "CreatedBy"
+	fieldUpdatedBy = "UpdatedBy"
+	fieldVersion   = "Version"
+
+	optimisticLockKey = "kit:optimistic_lock"
+
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+
+	defaultAuditTable = "audit_log"
+)
+
+// AuditConfig configures auditing
+type AuditConfig struct {
+	// Trail - if true, a row is written to audit table per each change
+	Trail bool
+	// Table - audit table name, "audit_log" by default
+	Table string
+}
+
+// AuditRecord is a row of audit trail
+type AuditRecord struct {
+	Id        uint64 `gorm:"primaryKey"`
+	TableName string
+	RecordId  string
+	Action    string
+	ChangedBy *string
+	RequestId *string
+	ChangedAt time.Time
+	Data      string `gorm:"type:jsonb"`
+}
+
+// RegisterCallbacks registers gorm callbacks populating CreatedBy / UpdatedBy, applying optimistic locking by Version
+// and optionally writing audit trail
+// models which don't have corresponding fields aren't affected
+func RegisterCallbacks(db *gorm.DB, cfg *AuditConfig) error {
+
+	cb := db.Callback()
+
+	if err := cb.Create().Before("gorm:create").Register("kit:before_create", beforeCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("kit:before_update", beforeUpdate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("kit:after_update", afterUpdate); err != nil {
+		return err
+	}
+
+	if cfg != nil && cfg.Trail {
+		table := cfg.Table
+		if table == "" {
+			table = defaultAuditTable
+		}
+		if err := cb.Create().After("gorm:create").Register("kit:audit_create", auditTrail(table, AuditActionCreate)); err != nil {
+			return err
+		}
+		if err := cb.Update().After("kit:after_update").Register("kit:audit_update", auditTrail(table, AuditActionUpdate)); err != nil {
+			return err
+		}
+		if err := cb.Delete().After("gorm:delete").Register("kit:audit_delete", auditTrail(table, AuditActionDelete)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// currentUser returns user id from the request context
+func currentUser(db *gorm.DB) *string {
+	if rCtx, ok := kitContext.Request(db.Statement.Context); ok && rCtx.GetUserId() != "" {
+		uid := rCtx.GetUserId()
+		return &uid
+	}
+	return nil
+}
+
+func beforeCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	if uid := currentUser(db); uid != nil {
+		for _, name := range []string{fieldCreatedBy, fieldUpdatedBy} {
+			if db.Statement.Schema.LookUpField(name) != nil {
+				db.Statement.SetColumn(name, uid, true)
+			}
+		}
+	}
+}
+
+func beforeUpdate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	if uid := currentUser(db); uid != nil && db.Statement.Schema.LookUpField(fieldUpdatedBy) != nil {
+		db.Statement.SetColumn(fieldUpdatedBy, uid, true)
+	}
+
+	// optimistic locking is applied only if a single model with populated version is updated
+	f := db.Statement.Schema.LookUpField(fieldVersion)
+	if f == nil || db.Statement.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+	v, zero := f.ValueOf(db.Statement.ReflectValue)
+	if zero {
+		return
+	}
+	version, ok := v.(int64)
+	if !ok {
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: version},
+	}})
+	db.Statement.SetColumn(fieldVersion, version+1, true)
+	db.InstanceSet(optimisticLockKey, version)
+}
+
+func afterUpdate(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if version, ok := db.InstanceGet(optimisticLockKey); ok && db.RowsAffected == 0 {
+		// restore version, so that model isn't left in inconsistent state
+		db.Statement.SetColumn(fieldVersion, version, true)
+		_ = db.AddError(ErrOptimisticLock(db.Statement.Context, db.Statement.Table))
+	}
+}
+
+// auditTrail writes a row per change within the same transaction
+func auditTrail(table, action string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.RowsAffected == 0 || db.Statement.Schema == nil || db.Statement.Table == table {
+			return
+		}
+
+		data, _ := json.Marshal(db.Statement.Dest)
+
+		rec := &AuditRecord{
+			TableName: db.Statement.Table,
+			RecordId:  recordId(db),
+			Action:    action,
+			ChangedBy: currentUser(db),
+			ChangedAt: db.Statement.DB.NowFunc(),
+			Data:      string(data),
+		}
+		if rCtx, ok := kitContext.Request(db.Statement.Context); ok && rCtx.GetRequestId() != "" {
+			rid := rCtx.GetRequestId()
+			rec.RequestId = &rid
+		}
+
+		if err := db.Session(&gorm.Session{NewDB: true}).Table(table).Create(rec).Error; err != nil {
+			_ = db.AddError(ErrAuditTrail(err))
+		}
+	}
+}
+
+// recordId returns primary key of the changed model, empty if it can't be identified (e.g. batch operations)
+func recordId(db *gorm.DB) string {
+	pf := db.Statement.Schema.PrioritizedPrimaryField
+	if pf == nil || db.Statement.ReflectValue.Kind() != reflect.Struct {
+		return ""
+	}
+	if v, zero := pf.ValueOf(db.Statement.ReflectValue); !zero {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
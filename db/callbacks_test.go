@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRun opens gorm without connection, statements are built but not executed (RowsAffected is always 0)
+func dryRun(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type auditItem struct {
+	Id   string
+	Name string
+	AuditDto
+}
+
+func userCtx(userId string) context.Context {
+	return kitContext.NewRequestCtx().WithNewRequestId().WithUser(userId, "").ToContext(context.Background())
+}
+
+func Test_Callbacks_Audit(t *testing.T) {
+	db := dryRun(t)
+	if err := RegisterCallbacks(db, &AuditConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	item := &auditItem{Id: "1"}
+	if err := db.WithContext(userCtx("u1")).Create(item).Error; err != nil {
+		t.Fatal(err)
+	}
+	if NullToString(item.CreatedBy) != "u1" || NullToString(item.UpdatedBy) != "u1" {
+		t.Fatalf("audit fields expected %v %v", item.CreatedBy, item.UpdatedBy)
+	}
+
+	stmt := db.WithContext(userCtx("u2")).Model(&auditItem{Id: "1"}).Update("name", "n").Statement
+	if !strings.Contains(stmt.SQL.String(), `"updated_by"=`) {
+		t.Fatalf("updated_by expected: %s", stmt.SQL.String())
+	}
+}
+
+func Test_Callbacks_OptimisticLock(t *testing.T) {
+	db := dryRun(t)
+	if err := RegisterCallbacks(db, &AuditConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// no rows are affected in dry run, as if the row were modified by another request
+	item := &auditItem{Id: "1", AuditDto: AuditDto{Version: 3}}
+	tx := db.WithContext(userCtx("u1")).Save(item)
+	assertCode(t, tx.Error, ErrCodeOptimisticLock)
+	if sql := tx.Statement.SQL.String(); !strings.Contains(sql, `"version"=$`) || !strings.Contains(sql, `"audit_items"."version" = $`) {
+		t.Fatalf("version condition expected: %s", sql)
+	}
+	if item.Version != 3 {
+		t.Fatalf("version must be restored, got %d", item.Version)
+	}
+
+	// version isn't populated, optimistic locking isn't applied
+	if err := db.WithContext(userCtx("u1")).Model(&auditItem{Id: "1"}).Update("name", "n").Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Callbacks_NotRegistered(t *testing.T) {
+	db := dryRun(t)
+
+	item := &auditItem{Id: "1", AuditDto: AuditDto{Version: 3}}
+	tx := db.WithContext(userCtx("u1")).Save(item)
+	if tx.Error != nil || strings.Contains(tx.Statement.SQL.String(), `"audit_items"."version" = $`) || item.CreatedBy != nil {
+		t.Fatalf("models mustn't be affected without callbacks: %s %v", tx.Statement.SQL.String(), tx.Error)
+	}
+}
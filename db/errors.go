@@ -1,6 +1,12 @@
 package db
 
-import "git.jetbrains.space/orbi/fcsd/kit/er"
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+)
 
 const (
 	ErrCodeGooseMigrationUp     = "DB-001"
@@ -12,26 +18,175 @@ const (
 	ErrCodeGooseMigrationUnLock = "DB-007"
 	ErrCodeMongoDbConnect       = "DB-008"
 	ErrCodeMangoNotPing         = "DB-009"
+	ErrCodeTxBegin              = "DB-010"
+	ErrCodeTxCommit             = "DB-011"
+	ErrCodeTxSavePoint          = "DB-012"
+	ErrCodeTxRetry              = "DB-013"
+	ErrCodeGooseMigrationDown   = "DB-014"
+	ErrCodeGooseMigrationRedo   = "DB-015"
+	ErrCodeGooseMigrationStatus = "DB-016"
+	ErrCodeGooseLockTimeout     = "DB-017"
+	ErrCodeMigrationCmdInvalid  = "DB-018"
+	ErrCodeMongoMigrationUp     = "DB-019"
+	ErrCodeMongoMigrationDown   = "DB-020"
+	ErrCodeMongoMigrationGetVer = "DB-021"
+	ErrCodeMongoMigrationLock   = "DB-022"
+	ErrCodeMongoMigrationUnLock = "DB-023"
+	ErrCodeMongoLockTimeout     = "DB-024"
+	ErrCodeMongoTls             = "DB-025"
+	ErrCodeMongoInvalidConfig   = "DB-026"
+	ErrCodeMongoTransaction     = "DB-027"
+	ErrCodePagingSortField      = "DB-028"
+	ErrCodePagingSortDirection  = "DB-029"
+	ErrCodePagingCursor         = "DB-030"
+	ErrCodePagingCount          = "DB-031"
+	ErrCodePagingFind           = "DB-032"
+	ErrCodeOptimisticLock       = "DB-033"
+	ErrCodeAuditTrail           = "DB-034"
+	ErrCodeRegisterCallbacks    = "DB-035"
+	ErrCodeTenantMissing        = "DB-036"
+	ErrCodeTenantMismatch       = "DB-037"
+	ErrCodeTenantSchema         = "DB-038"
+	ErrCodeTenantInvalid        = "DB-039"
+	ErrCodeMongoLockLost        = "DB-040"
 )
 
 var (
-	ErrGooseMigrationUp     = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeGooseMigrationUp, "").Err() }
-	ErrGooseMigrationGetVer = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeGooseMigrationGetVer, "").Err() }
-	ErrPostgresOpen         = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodePostgresOpen, "").Err() }
+	ErrGooseMigrationUp     = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGooseMigrationUp).Err() }
+	ErrGooseMigrationGetVer = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGooseMigrationGetVer).Err() }
+	ErrPostgresOpen         = func(cause error) error { return er.WrapWithCode(cause, ErrCodePostgresOpen).Err() }
 	ErrGooseFolderNotFound  = func(path string) error {
-		return er.WithBuilder(ErrCodeGooseFolderNotFound, "folder not found %s", path).Err()
+		return er.WithCode(ErrCodeGooseFolderNotFound, path).Err()
 	}
-	ErrGooseFolderOpen    = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeGooseFolderOpen, "folder open").Err() }
+	ErrGooseFolderOpen    = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGooseFolderOpen).Err() }
 	ErrGooseMigrationLock = func(cause error) error {
-		return er.WrapWithBuilder(cause, ErrCodeGooseMigrationLock, "locking before migration").Err()
+		return er.WrapWithCode(cause, ErrCodeGooseMigrationLock).Err()
 	}
 	ErrGooseMigrationUnLock = func(cause error) error {
-		return er.WrapWithBuilder(cause, ErrCodeGooseMigrationUnLock, "unlocking after migration").Err()
+		return er.WrapWithCode(cause, ErrCodeGooseMigrationUnLock).Err()
 	}
 	ErrMongoDbConnect = func(cause error) error {
-		return er.WrapWithBuilder(cause, ErrCodeMongoDbConnect, "can not connect to mongo").Err()
+		return er.WrapWithCode(cause, ErrCodeMongoDbConnect).Err()
 	}
 	ErrMongoNoPing = func(cause error) error {
-		return er.WrapWithBuilder(cause, ErrCodeMangoNotPing, "can not ping mongo.").Err()
+		return er.WrapWithCode(cause, ErrCodeMangoNotPing).Err()
+	}
+	ErrTxBegin     = func(cause error) error { return er.WrapWithCode(cause, ErrCodeTxBegin).Err() }
+	ErrTxCommit    = func(cause error) error { return er.WrapWithCode(cause, ErrCodeTxCommit).Err() }
+	ErrTxSavePoint = func(cause error) error { return er.WrapWithCode(cause, ErrCodeTxSavePoint).Err() }
+	ErrTxRetry     = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeTxRetry).Err()
+	}
+	ErrGooseMigrationDown        = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGooseMigrationDown).Err() }
+	ErrGooseMigrationRedo        = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGooseMigrationRedo).Err() }
+	ErrGooseMigrationStatus      = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGooseMigrationStatus).Err() }
+	ErrGooseMigrationLockTimeout = func(timeout time.Duration) error {
+		return er.WithCode(ErrCodeGooseLockTimeout).F(er.FF{"timeout": timeout.String()}).Err()
+	}
+	ErrMigrationCmdInvalid = func(usage string) error {
+		return er.WithCode(ErrCodeMigrationCmdInvalid, usage).Err()
+	}
+	ErrMongoMigrationUp = func(cause error, version int64) error {
+		return er.WrapWithCode(cause, ErrCodeMongoMigrationUp).F(er.FF{"version": version}).Err()
+	}
+	ErrMongoMigrationDown = func(cause error, version int64) error {
+		return er.WrapWithCode(cause, ErrCodeMongoMigrationDown).F(er.FF{"version": version}).Err()
+	}
+	ErrMongoMigrationGetVer = func(cause error) error { return er.WrapWithCode(cause, ErrCodeMongoMigrationGetVer).Err() }
+	ErrMongoMigrationLock   = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeMongoMigrationLock).Err()
+	}
+	ErrMongoMigrationUnLock = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeMongoMigrationUnLock).Err()
+	}
+	ErrMongoMigrationLockTimeout = func(timeout time.Duration) error {
+		return er.WithCode(ErrCodeMongoLockTimeout).F(er.FF{"timeout": timeout.String()}).Err()
+	}
+	ErrMongoTls           = func(cause error) error { return er.WrapWithCode(cause, ErrCodeMongoTls).Err() }
+	ErrMongoInvalidConfig = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeMongoInvalidConfig).Err()
+	}
+	ErrMongoTransaction = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeMongoTransaction).Err()
+	}
+	ErrMongoMigrationLockLost = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeMongoLockLost).Err()
+	}
+	ErrPagingSortField = func(ctx context.Context, field string) error {
+		return er.WithCode(ErrCodePagingSortField).F(er.FF{"field": field}).C(ctx).Err()
+	}
+	ErrPagingSortDirection = func(ctx context.Context, dir string) error {
+		return er.WithCode(ErrCodePagingSortDirection).F(er.FF{"direction": dir}).C(ctx).Err()
+	}
+	ErrPagingCursor = func(ctx context.Context, cause error) error {
+		return er.WrapWithCode(cause, ErrCodePagingCursor).C(ctx).Err()
+	}
+	ErrPagingCount    = func(cause error) error { return er.WrapWithCode(cause, ErrCodePagingCount).Err() }
+	ErrPagingFind     = func(cause error) error { return er.WrapWithCode(cause, ErrCodePagingFind).Err() }
+	ErrOptimisticLock = func(ctx context.Context, table string) error {
+		return er.WithCode(ErrCodeOptimisticLock).F(er.FF{"table": table}).C(ctx).Err()
+	}
+	ErrAuditTrail = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeAuditTrail).Err()
+	}
+	ErrRegisterCallbacks = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeRegisterCallbacks).Err()
+	}
+	ErrTenantMissing = func(ctx context.Context, table string) error {
+		return er.WithCode(ErrCodeTenantMissing).F(er.FF{"table": table}).C(ctx).Err()
+	}
+	ErrTenantMismatch = func(ctx context.Context, table string) error {
+		return er.WithCode(ErrCodeTenantMismatch).F(er.FF{"table": table}).C(ctx).Err()
+	}
+	ErrTenantSchema = func(cause error, tenantId string) error {
+		return er.WrapWithCode(cause, ErrCodeTenantSchema).F(er.FF{"tenant": tenantId}).Err()
+	}
+	ErrTenantInvalid = func(ctx context.Context, tenantId string) error {
+		return er.WithCode(ErrCodeTenantInvalid).F(er.FF{"tenant": tenantId}).C(ctx).Err()
 	}
 )
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeGooseMigrationUp, Message: "applying migrations failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGooseMigrationGetVer, Message: "getting db version failed"},
+		&er.CodeDef{Code: ErrCodePostgresOpen, Message: "opening postgres connection failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGooseFolderNotFound, Message: "folder not found %s", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGooseFolderOpen, Message: "folder open", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGooseMigrationLock, Message: "locking before migration"},
+		&er.CodeDef{Code: ErrCodeGooseMigrationUnLock, Message: "unlocking after migration", Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeMongoDbConnect, Message: "can not connect to mongo", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeMangoNotPing, Message: "can not ping mongo.", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeTxBegin, Message: "begin transaction"},
+		&er.CodeDef{Code: ErrCodeTxCommit, Message: "commit transaction"},
+		&er.CodeDef{Code: ErrCodeTxSavePoint, Message: "savepoint"},
+		&er.CodeDef{Code: ErrCodeTxRetry, Message: "transaction retries exceeded"},
+		&er.CodeDef{Code: ErrCodeGooseMigrationDown, Message: "rolling back migrations failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGooseMigrationRedo, Message: "redoing migration failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGooseMigrationStatus, Message: "getting migrations status failed"},
+		&er.CodeDef{Code: ErrCodeGooseLockTimeout, Message: "migration lock isn't acquired within timeout"},
+		&er.CodeDef{Code: ErrCodeMigrationCmdInvalid, Message: "invalid migration command\n%s", Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeMongoMigrationUp, Message: "applying migration", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeMongoMigrationDown, Message: "rolling back migration", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeMongoMigrationGetVer, Message: "getting mongo migration version failed"},
+		&er.CodeDef{Code: ErrCodeMongoMigrationLock, Message: "locking before migration"},
+		&er.CodeDef{Code: ErrCodeMongoMigrationUnLock, Message: "unlocking after migration", Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeMongoLockTimeout, Message: "migration lock isn't acquired within timeout"},
+		&er.CodeDef{Code: ErrCodeMongoTls, Message: "load tls config", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeMongoInvalidConfig, Message: "invalid mongo config", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeMongoTransaction, Message: "mongo transaction failed"},
+		&er.CodeDef{Code: ErrCodePagingSortField, Message: "sorting by field isn't allowed", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodePagingSortDirection, Message: "invalid sort direction", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodePagingCursor, Message: "invalid cursor", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodePagingCount, Message: "count items"},
+		&er.CodeDef{Code: ErrCodePagingFind, Message: "find page"},
+		&er.CodeDef{Code: ErrCodeOptimisticLock, Message: "record has been modified or deleted by another request", HttpStatus: http.StatusConflict, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeAuditTrail, Message: "write audit trail"},
+		&er.CodeDef{Code: ErrCodeRegisterCallbacks, Message: "register gorm callbacks", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeTenantMissing, Message: "tenant isn't specified", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeTenantMismatch, Message: "access to data of another tenant denied", HttpStatus: http.StatusForbidden, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeTenantSchema, Message: "create tenant schema"},
+		&er.CodeDef{Code: ErrCodeTenantInvalid, Message: "invalid tenant id", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeMongoLockLost, Message: "migration lock is lost", Severity: er.SeverityCritical},
+	)
+}
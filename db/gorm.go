@@ -1,12 +1,27 @@
 package db
 
-import "time"
+import (
+	"time"
 
-// BaseDto
+	"gorm.io/gorm"
+)
+
+// BaseDto provides timestamps and soft delete
+// DeletedAt is gorm.DeletedAt, so queries automatically exclude soft deleted rows and Delete sets deleted_at instead of removing row
 type BaseDto struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	DeletedAt *time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// AuditDto extends BaseDto with auditing and optimistic locking
+// CreatedBy / UpdatedBy are populated from the request context user id by callbacks registered with RegisterCallbacks (DbConfig.Audit)
+// Version is incremented on each update, update of a stale version fails with ErrOptimisticLock
+type AuditDto struct {
+	BaseDto
+	CreatedBy *string
+	UpdatedBy *string
+	Version   int64 `gorm:"not null;default:1"`
 }
 
 // StringToNull transforms empty string to nil string, so that gorm stores it as NULL
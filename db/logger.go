@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	kitLog "git.jetbrains.space/orbi/fcsd/kit/log"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+const (
+	GormLogSilent = "silent"
+	GormLogError  = "error"
+	GormLogWarn   = "warn"
+	GormLogInfo   = "info"
+
+	defaultSlowThreshold = time.Second
+)
+
+// GormLogConfig configures gorm logging
+type GormLogConfig struct {
+	// Level - gorm log level (silent, error, warn, info). With "info" each statement is logged at trace level
+	Level string
+	// SlowThreshold - statements executed as long or longer are logged as warnings
+	SlowThreshold time.Duration `config:"slow-threshold"`
+	// IgnoreNotFound - if true, gorm.ErrRecordNotFound isn't logged as error
+	IgnoreNotFound bool `config:"ignore-not-found"`
+}
+
+// gormLoggerAdapter implements gorm logger interface and writes entries through CLogger
+type gormLoggerAdapter struct {
+	logger         kitLog.CLoggerFunc
+	level          gormLogger.LogLevel
+	slowThreshold  time.Duration
+	ignoreNotFound bool
+}
+
+// NewGormLogger creates a gorm logger writing structured entries via CLogger
+func NewGormLogger(logger kitLog.CLoggerFunc, cfg *GormLogConfig) gormLogger.Interface {
+	a := &gormLoggerAdapter{
+		logger:        logger,
+		level:         gormLogger.Info,
+		slowThreshold: defaultSlowThreshold,
+	}
+	if cfg != nil {
+		a.level = gormLogLevel(cfg.Level)
+		a.ignoreNotFound = cfg.IgnoreNotFound
+		if cfg.SlowThreshold > 0 {
+			a.slowThreshold = cfg.SlowThreshold
+		}
+	}
+	return a
+}
+
+func gormLogLevel(level string) gormLogger.LogLevel {
+	switch level {
+	case GormLogSilent:
+		return gormLogger.Silent
+	case GormLogError:
+		return gormLogger.Error
+	case GormLogWarn:
+		return gormLogger.Warn
+	default:
+		return gormLogger.Info
+	}
+}
+
+func (a *gormLoggerAdapter) l(ctx context.Context) kitLog.CLogger {
+	return a.logger().Pr("db").Cmp("gorm").C(ctx)
+}
+
+func (a *gormLoggerAdapter) LogMode(level gormLogger.LogLevel) gormLogger.Interface {
+	clone := *a
+	clone.level = level
+	return &clone
+}
+
+func (a *gormLoggerAdapter) Info(ctx context.Context, msg string, args ...interface{}) {
+	if a.level >= gormLogger.Info {
+		a.l(ctx).DbgF(msg, args...)
+	}
+}
+
+func (a *gormLoggerAdapter) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if a.level >= gormLogger.Warn {
+		a.l(ctx).WarnF(msg, args...)
+	}
+}
+
+func (a *gormLoggerAdapter) Error(ctx context.Context, msg string, args ...interface{}) {
+	if a.level >= gormLogger.Error {
+		a.l(ctx).ErrF(msg, args...)
+	}
+}
+
+func (a *gormLoggerAdapter) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if a.level <= gormLogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+
+	switch {
+	case err != nil && a.level >= gormLogger.Error && !(a.ignoreNotFound && errors.Is(err, gormLogger.ErrRecordNotFound)):
+		sql, rows := fc()
+		a.l(ctx).F(queryFields(elapsed, rows, sql)).E(err).Err("query failed")
+	case elapsed >= a.slowThreshold && a.level >= gormLogger.Warn:
+		sql, rows := fc()
+		a.l(ctx).F(queryFields(elapsed, rows, sql)).WarnF("slow query >= %v", a.slowThreshold)
+	case a.level >= gormLogger.Info:
+		sql, rows := fc()
+		a.l(ctx).F(queryFields(elapsed, rows, sql)).Trc("query")
+	}
+}
+
+func queryFields(elapsed time.Duration, rows int64, sql string) kitLog.FF {
+	ff := kitLog.FF{
+		"duration": fmt.Sprintf("%.3fms", float64(elapsed.Nanoseconds())/1e6),
+		"sql":      sql,
+	}
+	// gorm passes -1 when rows aren't applicable
+	if rows >= 0 {
+		ff["rows"] = rows
+	}
+	return ff
+}
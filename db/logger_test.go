@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	kitLog "git.jetbrains.space/orbi/fcsd/kit/log"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+func testGormLogger(cfg *GormLogConfig) (gormLogger.Interface, *test.Hook) {
+	logger := kitLog.Init(&kitLog.Config{Level: kitLog.TraceLevel})
+	logger.Logrus.SetOutput(ioutil.Discard)
+	hook := test.NewLocal(logger.Logrus)
+	return NewGormLogger(func() kitLog.CLogger { return kitLog.L(logger) }, cfg), hook
+}
+
+func Test_GormLogLevel(t *testing.T) {
+	for level, expected := range map[string]gormLogger.LogLevel{
+		GormLogSilent: gormLogger.Silent,
+		GormLogError:  gormLogger.Error,
+		GormLogWarn:   gormLogger.Warn,
+		GormLogInfo:   gormLogger.Info,
+		"":            gormLogger.Info,
+	} {
+		if l := gormLogLevel(level); l != expected {
+			t.Fatalf("%s: unexpected level %v", level, l)
+		}
+	}
+}
+
+func Test_GormLoggerTrace(t *testing.T) {
+	sql := func() (string, int64) { return "select 1", 1 }
+	fast, slow := time.Now(), time.Now().Add(-time.Millisecond*20)
+
+	tests := []struct {
+		name  string
+		cfg   *GormLogConfig
+		begin time.Time
+		err   error
+		level logrus.Level
+	}{
+		{"query", nil, fast, nil, logrus.TraceLevel},
+		{"slow query", &GormLogConfig{SlowThreshold: time.Millisecond * 10}, slow, nil, logrus.WarnLevel},
+		{"error", &GormLogConfig{Level: GormLogError}, fast, errors.New("failed"), logrus.ErrorLevel},
+		{"not found", &GormLogConfig{Level: GormLogError}, fast, gormLogger.ErrRecordNotFound, logrus.ErrorLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, hook := testGormLogger(tt.cfg)
+			l.Trace(context.Background(), tt.begin, sql, tt.err)
+			e := hook.LastEntry()
+			if e == nil || e.Level != tt.level {
+				t.Fatalf("%v entry expected, got %v", tt.level, e)
+			}
+			if e.Data["sql"] != "select 1" || e.Data["rows"] != int64(1) {
+				t.Fatalf("unexpected fields %v", e.Data)
+			}
+		})
+	}
+
+	// entries below the level aren't logged
+	silent := []struct {
+		name  string
+		cfg   *GormLogConfig
+		begin time.Time
+		err   error
+	}{
+		{"silent", &GormLogConfig{Level: GormLogSilent}, slow, errors.New("failed")},
+		{"query on warn", &GormLogConfig{Level: GormLogWarn}, fast, nil},
+		{"slow query on error", &GormLogConfig{Level: GormLogError, SlowThreshold: time.Millisecond * 10}, slow, nil},
+		{"ignored not found", &GormLogConfig{Level: GormLogError, IgnoreNotFound: true}, fast, gormLogger.ErrRecordNotFound},
+	}
+	for _, tt := range silent {
+		t.Run(tt.name, func(t *testing.T) {
+			l, hook := testGormLogger(tt.cfg)
+			l.Trace(context.Background(), tt.begin, sql, tt.err)
+			if e := hook.LastEntry(); e != nil {
+				t.Fatalf("no entry expected, got %v", e)
+			}
+		})
+	}
+}
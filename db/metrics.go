@@ -0,0 +1,56 @@
+package db
+
+import (
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"gorm.io/gorm"
+)
+
+const metricsStartKey = "kit:metrics_start"
+
+// RegisterMetrics registers gorm callbacks measuring query durations by operation and table
+func RegisterMetrics(db *gorm.DB) error {
+
+	cb := db.Callback()
+
+	registers := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+
+	for _, r := range registers {
+		if err := r.before("kit:metrics_before_"+r.operation, metricsBefore); err != nil {
+			return err
+		}
+		if err := r.after("kit:metrics_after_"+r.operation, metricsAfter(r.operation)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func metricsBefore(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+func metricsAfter(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		start, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		metrics.DbQueryDuration.
+			WithLabelValues(operation, db.Statement.Table, metrics.Status(db.Error)).
+			Observe(time.Since(start.(time.Time)).Seconds())
+	}
+}
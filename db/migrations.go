@@ -1,37 +1,206 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"git.jetbrains.space/orbi/fcsd/kit/log"
-	_ "github.com/lib/pq"
-	"github.com/pressly/goose"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/log"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+const (
+	pgMigrationAdvisoryLockId = 789654123
+	lockRetryInterval         = time.Millisecond * 500
 )
 
-const pgMigrationAdvisoryLockId = 789654123
+// MigrationFunc is a Go-coded migration step executed within transaction
+type MigrationFunc func(tx *sql.Tx) error
+
+// MigrationStatus describes state of a single migration
+type MigrationStatus struct {
+	Version   int64      // Version - migration version
+	Source    string     // Source - migration file name
+	Applied   bool       // Applied - if migration is applied
+	AppliedAt *time.Time // AppliedAt - when migration was applied
+}
 
 type Migration interface {
+	// Up applies all pending migrations
 	Up() error
+	// UpTo applies pending migrations up to the given version (inclusive)
+	UpTo(version int64) error
+	// Down rolls back the last applied migration
+	Down() error
+	// DownTo rolls back migrations down to the given version (exclusive)
+	DownTo(version int64) error
+	// Redo rolls back the last applied migration and applies it again
+	Redo() error
+	// Version returns the current db version
+	Version() (int64, error)
+	// Status returns status of all known migrations
+	Status() ([]*MigrationStatus, error)
+}
+
+// MigrationOptions allows customizing migration
+type MigrationOptions struct {
+	// FS - file system migrations are read from (e.g. embed.FS). If nil, os file system is used
+	FS fs.FS
+	// FailOnLockErr - if true, migration fails if advisory lock cannot be acquired, otherwise error is just logged
+	FailOnLockErr bool
+	// LockTimeout - how long to wait for advisory lock, waits infinitely if empty
+	LockTimeout time.Duration
 }
 
 type migImpl struct {
 	db     *sql.DB
 	source string
 	logger log.CLoggerFunc
+	opts   *MigrationOptions
 }
 
 func NewMigration(db *sql.DB, source string, logger log.CLoggerFunc) Migration {
+	return NewMigrationWithOptions(db, source, logger, &MigrationOptions{})
+}
+
+// NewMigrationWithOptions creates migration with custom options
+func NewMigrationWithOptions(db *sql.DB, source string, logger log.CLoggerFunc, opts *MigrationOptions) Migration {
+	if opts == nil {
+		opts = &MigrationOptions{}
+	}
 	return &migImpl{
 		db:     db,
 		source: source,
 		logger: logger,
+		opts:   opts,
 	}
 }
 
+// AddMigration registers Go-coded migration
+// name must start with version number followed by underscore, e.g. "00005_fill_defaults.go"
+func AddMigration(name string, up, down MigrationFunc) {
+	goose.AddNamedMigration(name, up, down)
+}
+
+func (m *migImpl) l() log.CLogger {
+	return m.logger().Cmp("db-migration")
+}
+
 func (m *migImpl) Up() error {
-	l := m.logger().Cmp("db-migration").Mth("up").InfF("applying from %s ...", m.source)
+	return m.run("up", func() error {
+		if err := goose.Up(m.db, m.source); err != nil {
+			return ErrGooseMigrationUp(err)
+		}
+		return nil
+	})
+}
+
+func (m *migImpl) UpTo(version int64) error {
+	return m.run("up-to", func() error {
+		if err := goose.UpTo(m.db, m.source, version); err != nil {
+			return ErrGooseMigrationUp(err)
+		}
+		return nil
+	})
+}
+
+func (m *migImpl) Down() error {
+	return m.run("down", func() error {
+		if err := goose.Down(m.db, m.source); err != nil {
+			return ErrGooseMigrationDown(err)
+		}
+		return nil
+	})
+}
+
+func (m *migImpl) DownTo(version int64) error {
+	return m.run("down-to", func() error {
+		if err := goose.DownTo(m.db, m.source, version); err != nil {
+			return ErrGooseMigrationDown(err)
+		}
+		return nil
+	})
+}
+
+func (m *migImpl) Redo() error {
+	return m.run("redo", func() error {
+		if err := goose.Redo(m.db, m.source); err != nil {
+			return ErrGooseMigrationRedo(err)
+		}
+		return nil
+	})
+}
+
+func (m *migImpl) Version() (int64, error) {
+	m.setup()
+	version, err := goose.GetDBVersion(m.db)
+	if err != nil {
+		return 0, ErrGooseMigrationGetVer(err)
+	}
+	return version, nil
+}
+
+func (m *migImpl) Status() ([]*MigrationStatus, error) {
+
+	if err := m.checkSource(); err != nil {
+		return nil, err
+	}
+	m.setup()
+
+	migrations, err := goose.CollectMigrations(m.source, 0, math.MaxInt64)
+	if err != nil {
+		return nil, ErrGooseMigrationStatus(err)
+	}
+
+	// ensures version table exists on a pristine db
+	if _, err := goose.EnsureDBVersion(m.db); err != nil {
+		return nil, ErrGooseMigrationStatus(err)
+	}
+
+	q := fmt.Sprintf("select tstamp, is_applied from %s where version_id = $1 order by id desc limit 1", goose.TableName())
+
+	var res []*MigrationStatus
+	for _, mg := range migrations {
+		st := &MigrationStatus{
+			Version: mg.Version,
+			Source:  filepath.Base(mg.Source),
+		}
+		var tstamp time.Time
+		err := m.db.QueryRow(q, mg.Version).Scan(&tstamp, &st.Applied)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, ErrGooseMigrationStatus(err)
+		}
+		if st.Applied {
+			st.AppliedAt = &tstamp
+		}
+		res = append(res, st)
+	}
+
+	return res, nil
+}
+
+// setup configures goose before running a command
+func (m *migImpl) setup() {
+	goose.SetBaseFS(m.opts.FS)
+	goose.SetLogger(&gooseLogger{logger: m.l})
+}
+
+func (m *migImpl) checkSource() error {
+	if m.opts.FS != nil {
+		if _, err := fs.Stat(m.opts.FS, m.source); err != nil {
+			if os.IsNotExist(err) {
+				return ErrGooseFolderNotFound(m.source)
+			}
+			return ErrGooseFolderOpen(err)
+		}
+		return nil
+	}
 
 	absPath, _ := filepath.Abs(m.source)
 	if _, err := os.Stat(absPath); err != nil {
@@ -40,23 +209,47 @@ func (m *migImpl) Up() error {
 		}
 		return ErrGooseFolderOpen(err)
 	}
+	return nil
+}
+
+// run executes migration command under advisory lock
+func (m *migImpl) run(cmd string, fn func() error) error {
+	l := m.l().Mth(cmd).InfF("applying from %s ...", m.source)
+
+	if err := m.checkSource(); err != nil {
+		return err
+	}
+	m.setup()
 
 	// lock before migration (applying advisory lock) to guaranty exclusive migration execution
-	_, err := m.db.Exec(fmt.Sprintf("select pg_advisory_lock(%d)", pgMigrationAdvisoryLockId))
+	// lock is session level, so lock and unlock must be executed on the same connection
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
 	if err != nil {
-		m.logger().E(ErrGooseMigrationLock(err)).Err()
+		return ErrGooseMigrationLock(err)
 	}
-	// unlock after migration
-	defer func() {
-		if _, err := m.db.Exec(fmt.Sprintf("select pg_advisory_unlock(%d)", pgMigrationAdvisoryLockId)); err != nil {
-			m.logger().E(ErrGooseMigrationUnLock(err)).Err()
-		}
-	}()
+	defer func() { _ = conn.Close() }()
 
-	err = goose.Up(m.db, m.source)
+	locked, err := m.lock(ctx, conn)
 	if err != nil {
-		return ErrGooseMigrationUp(err)
+		if m.opts.FailOnLockErr {
+			return err
+		}
+		m.l().Mth(cmd).E(err).Err()
+	}
+	// unlock after migration
+	if locked {
+		defer func() {
+			if _, err := conn.ExecContext(ctx, "select pg_advisory_unlock($1)", pgMigrationAdvisoryLockId); err != nil {
+				m.l().Mth(cmd).E(ErrGooseMigrationUnLock(err)).Err()
+			}
+		}()
+	}
+
+	if err := fn(); err != nil {
+		return err
 	}
+
 	version, err := goose.GetDBVersion(m.db)
 	if err != nil {
 		return ErrGooseMigrationGetVer(err)
@@ -64,3 +257,54 @@ func (m *migImpl) Up() error {
 	l.InfF("ok, version: %d", version)
 	return nil
 }
+
+// lock acquires advisory lock waiting not longer than lock timeout
+func (m *migImpl) lock(ctx context.Context, conn *sql.Conn) (bool, error) {
+
+	if m.opts.LockTimeout <= 0 {
+		if _, err := conn.ExecContext(ctx, "select pg_advisory_lock($1)", pgMigrationAdvisoryLockId); err != nil {
+			return false, ErrGooseMigrationLock(err)
+		}
+		return true, nil
+	}
+
+	deadline := time.Now().Add(m.opts.LockTimeout)
+	for {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, "select pg_try_advisory_lock($1)", pgMigrationAdvisoryLockId).Scan(&locked); err != nil {
+			return false, ErrGooseMigrationLock(err)
+		}
+		if locked {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, ErrGooseMigrationLockTimeout(m.opts.LockTimeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// gooseLogger redirects goose output to CLogger
+type gooseLogger struct {
+	logger func() log.CLogger
+}
+
+func (g *gooseLogger) Fatal(v ...interface{}) {
+	g.logger().Fatal(v...)
+}
+
+func (g *gooseLogger) Fatalf(format string, v ...interface{}) {
+	g.logger().FatalF(format, v...)
+}
+
+func (g *gooseLogger) Print(v ...interface{}) {
+	g.logger().Dbg(v...)
+}
+
+func (g *gooseLogger) Println(v ...interface{}) {
+	g.logger().Dbg(v...)
+}
+
+func (g *gooseLogger) Printf(format string, v ...interface{}) {
+	g.logger().DbgF(format, v...)
+}
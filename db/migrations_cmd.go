@@ -0,0 +1,99 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	MigCmdUp      = "up"
+	MigCmdUpTo    = "up-to"
+	MigCmdDown    = "down"
+	MigCmdDownTo  = "down-to"
+	MigCmdRedo    = "redo"
+	MigCmdStatus  = "status"
+	MigCmdVersion = "version"
+)
+
+// MigrationCmdUsage describes commands supported by RunMigrationCmd
+const MigrationCmdUsage = `usage: migrate <command> [version]
+
+commands:
+    up                 apply all pending migrations
+    up-to <version>    apply pending migrations up to version
+    down               roll back the last migration
+    down-to <version>  roll back migrations down to version
+    redo               roll back the last migration and apply it again
+    status             print status of all migrations
+    version            print the current db version
+`
+
+// RunMigrationCmd executes migration command given as command line args (without subcommand name itself)
+// it allows services to mount migration as a subcommand, e.g. "svc migrate up"
+// output of status and version commands is written to w
+func RunMigrationCmd(m Migration, w io.Writer, args []string) error {
+
+	if len(args) == 0 {
+		return ErrMigrationCmdInvalid(MigrationCmdUsage)
+	}
+
+	cmd := args[0]
+
+	versionArg := func() (int64, error) {
+		if len(args) < 2 {
+			return 0, ErrMigrationCmdInvalid(MigrationCmdUsage)
+		}
+		v, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return 0, ErrMigrationCmdInvalid(MigrationCmdUsage)
+		}
+		return v, nil
+	}
+
+	switch cmd {
+	case MigCmdUp:
+		return m.Up()
+	case MigCmdUpTo:
+		v, err := versionArg()
+		if err != nil {
+			return err
+		}
+		return m.UpTo(v)
+	case MigCmdDown:
+		return m.Down()
+	case MigCmdDownTo:
+		v, err := versionArg()
+		if err != nil {
+			return err
+		}
+		return m.DownTo(v)
+	case MigCmdRedo:
+		return m.Redo()
+	case MigCmdVersion:
+		v, err := m.Version()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "version: %d\n", v)
+		return nil
+	case MigCmdStatus:
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "    %-24s    %s\n", "Applied At", "Migration")
+		_, _ = fmt.Fprintln(w, "    =======================================")
+		for _, st := range statuses {
+			appliedAt := "Pending"
+			if st.AppliedAt != nil {
+				appliedAt = st.AppliedAt.Format(time.ANSIC)
+			}
+			_, _ = fmt.Fprintf(w, "    %-24s -- %s\n", appliedAt, st.Source)
+		}
+		return nil
+	default:
+		return ErrMigrationCmdInvalid(MigrationCmdUsage)
+	}
+}
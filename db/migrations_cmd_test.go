@@ -0,0 +1,85 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+)
+
+// migrationMock records called commands
+type migrationMock struct {
+	calls []string
+}
+
+func (m *migrationMock) call(c string) error {
+	m.calls = append(m.calls, c)
+	return nil
+}
+
+func (m *migrationMock) Up() error               { return m.call("up") }
+func (m *migrationMock) UpTo(v int64) error      { return m.call(fmt.Sprintf("up-to %d", v)) }
+func (m *migrationMock) Down() error             { return m.call("down") }
+func (m *migrationMock) DownTo(v int64) error    { return m.call(fmt.Sprintf("down-to %d", v)) }
+func (m *migrationMock) Redo() error             { return m.call("redo") }
+func (m *migrationMock) Version() (int64, error) { return 3, m.call("version") }
+func (m *migrationMock) Status() ([]*MigrationStatus, error) {
+	appliedAt := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []*MigrationStatus{
+		{Version: 1, Source: "1_init.sql", Applied: true, AppliedAt: &appliedAt},
+		{Version: 2, Source: "2_users.sql"},
+	}, m.call("status")
+}
+
+func Test_RunMigrationCmd(t *testing.T) {
+	for _, args := range [][]string{{"up"}, {"up-to", "2"}, {"down"}, {"down-to", "1"}, {"redo"}} {
+		m := &migrationMock{}
+		if err := RunMigrationCmd(m, &bytes.Buffer{}, args); err != nil {
+			t.Fatal(err)
+		}
+		if len(m.calls) != 1 || m.calls[0] != strings.Join(args, " ") {
+			t.Fatalf("%v: unexpected calls %v", args, m.calls)
+		}
+	}
+
+	w := &bytes.Buffer{}
+	if err := RunMigrationCmd(&migrationMock{}, w, []string{"version"}); err != nil || w.String() != "version: 3\n" {
+		t.Fatalf("unexpected output %q %v", w.String(), err)
+	}
+
+	w.Reset()
+	if err := RunMigrationCmd(&migrationMock{}, w, []string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.String(), "Sat Jan  2 03:04:05 2021 -- 1_init.sql") || !strings.Contains(w.String(), "Pending") {
+		t.Fatalf("unexpected status %s", w.String())
+	}
+}
+
+func Test_RunMigrationCmdInvalid(t *testing.T) {
+	for _, args := range [][]string{nil, {"unknown"}, {"up-to"}, {"down-to", "x"}} {
+		m := &migrationMock{}
+		err := RunMigrationCmd(m, &bytes.Buffer{}, args)
+		assertCode(t, err, ErrCodeMigrationCmdInvalid)
+		if len(m.calls) != 0 {
+			t.Fatalf("%v: unexpected calls %v", args, m.calls)
+		}
+		if appErr, _ := er.Is(err); !strings.Contains(appErr.Message(), "usage: migrate") {
+			t.Fatalf("usage expected, got %s", appErr.Message())
+		}
+	}
+}
+
+func Test_MigrationErrorMessages(t *testing.T) {
+	cause := errors.New("cause")
+	for _, err := range []error{ErrGooseMigrationDown(cause), ErrGooseMigrationRedo(cause), ErrGooseMigrationStatus(cause)} {
+		appErr, _ := er.Is(err)
+		if d, _ := er.Lookup(appErr.Code()); d.Message == "" || !strings.HasPrefix(appErr.Message(), d.Message) {
+			t.Fatalf("message of catalog expected, got %q", appErr.Message())
+		}
+	}
+}
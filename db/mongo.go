@@ -3,9 +3,21 @@ package db
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"git.jetbrains.space/orbi/fcsd/kit/utils"
 	"go.mongodb.org/mongo-driver/mongo"
 	options2 "go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+const (
+	defaultMongoConnectTimeout = time.Second * 10
+	defaultMongoCloseTimeout   = time.Second * 5
+	mongoWriteConcernMajority  = "majority"
 )
 
 type MongoStorage struct {
@@ -14,11 +26,36 @@ type MongoStorage struct {
 }
 
 type MongoClusterConfig struct {
+	// Uri - full connection URI. If specified, Database hosts are ignored, other params override params from URI
+	Uri      string
 	Database []*MongoConnectConfig
+	// ReplUri - replica set name, not set if empty
 	ReplUri  string
 	DbName   string
 	User     string
 	Password string
+	// AuthSource - database to authenticate against, DbName is used if empty
+	AuthSource string `config:"auth-source"`
+	// Tls - enables TLS
+	Tls bool
+	// TlsCaFile - CA certificate used to verify server
+	TlsCaFile string `config:"tls-ca-file"`
+	// TlsCertFile - client certificate
+	TlsCertFile string `config:"tls-cert-file"`
+	// TlsKeyFile - client private key
+	TlsKeyFile string `config:"tls-key-file"`
+	// ReadPreference - primary, primaryPreferred, secondary, secondaryPreferred, nearest
+	ReadPreference string `config:"read-preference"`
+	// WriteConcern - "majority" or number of nodes
+	WriteConcern string `config:"write-concern"`
+	// MaxPoolSize - max number of connections in pool
+	MaxPoolSize uint64 `config:"max-pool-size"`
+	// MinPoolSize - min number of connections in pool
+	MinPoolSize uint64 `config:"min-pool-size"`
+	// ConnectTimeout - timeout of connection establishment, 10s by default
+	ConnectTimeout time.Duration `config:"connect-timeout"`
+	// ServerSelectionTimeout - how long to wait for a suitable server
+	ServerSelectionTimeout time.Duration `config:"server-selection-timeout"`
 }
 
 type MongoConnectConfig struct {
@@ -26,48 +63,151 @@ type MongoConnectConfig struct {
 	Host string
 }
 
-func OpenMongoConnect(config *MongoClusterConfig) (*MongoStorage, error) {
-	var hosts []string
+func (c *MongoClusterConfig) clientOptions() (*options2.ClientOptions, error) {
+
+	opts := options2.Client()
 
-	s := &MongoStorage{}
+	if c.Uri != "" {
+		opts.ApplyURI(c.Uri)
+	} else {
+		var hosts []string
+		for _, value := range c.Database {
+			hosts = append(hosts, fmt.Sprintf("%s:%s", value.Host, value.Port))
+		}
+		opts.SetHosts(hosts)
+	}
 
-	for _, value := range config.Database {
-		dsn := fmt.Sprintf("mongodb://%s:%s@%s:%s",
-			config.User,
-			config.Password,
-			value.Host,
-			value.Port,
-		)
+	if c.User != "" {
+		authSource := c.AuthSource
+		if authSource == "" {
+			authSource = c.DbName
+		}
+		opts.SetAuth(options2.Credential{
+			AuthSource: authSource,
+			Username:   c.User,
+			Password:   c.Password,
+		})
+	}
 
-		hosts = append(hosts, dsn)
+	if c.ReplUri != "" {
+		opts.SetReplicaSet(c.ReplUri)
 	}
 
-	options := &options2.ClientOptions{
-		Auth: &options2.Credential{
-			AuthSource: config.DbName,
-			Username:   config.User,
-			Password:   config.Password,
-		},
-		Hosts:      hosts,
-		ReplicaSet: &config.ReplUri,
+	if c.Tls {
+		tlsCfg, err := utils.LoadTlsConfig(c.TlsCaFile, c.TlsCertFile, c.TlsKeyFile)
+		if err != nil {
+			return nil, ErrMongoTls(err)
+		}
+		opts.SetTLSConfig(tlsCfg)
 	}
 
-	client, err := mongo.Connect(context.TODO(), options)
+	if c.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(c.ReadPreference)
+		if err != nil {
+			return nil, ErrMongoInvalidConfig(err)
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, ErrMongoInvalidConfig(err)
+		}
+		opts.SetReadPreference(rp)
+	}
+
+	if c.WriteConcern != "" {
+		if c.WriteConcern == mongoWriteConcernMajority {
+			opts.SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
+		} else {
+			w, err := strconv.Atoi(c.WriteConcern)
+			if err != nil {
+				return nil, ErrMongoInvalidConfig(err)
+			}
+			opts.SetWriteConcern(writeconcern.New(writeconcern.W(w)))
+		}
+	}
+
+	if c.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(c.MaxPoolSize)
+	}
+	if c.MinPoolSize > 0 {
+		opts.SetMinPoolSize(c.MinPoolSize)
+	}
+
+	if c.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(c.ConnectTimeout)
+	} else if opts.ConnectTimeout == nil {
+		opts.SetConnectTimeout(defaultMongoConnectTimeout)
+	}
+
+	if c.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(c.ServerSelectionTimeout)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, ErrMongoInvalidConfig(err)
+	}
 
+	return opts, nil
+}
+
+func OpenMongoConnect(config *MongoClusterConfig) (*MongoStorage, error) {
+
+	options, err := config.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := *options.ConnectTimeout
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options)
 	if err != nil {
 		return nil, ErrMongoDbConnect(err)
 	}
 
-	s.Instance = client.Database(config.DbName)
+	pingCtx, pingCancel := context.WithTimeout(context.Background(), timeout)
+	defer pingCancel()
 
-	if err = client.Ping(context.TODO(), readpref.Primary()); err != nil {
+	if err = client.Ping(pingCtx, readpref.Primary()); err != nil {
+		// don't leak client if server isn't available
+		_ = client.Disconnect(context.Background())
 		return nil, ErrMongoNoPing(err)
 	}
 
-	return s, nil
+	return &MongoStorage{
+		Instance: client.Database(config.DbName),
+		DBName:   config.DbName,
+	}, nil
+}
+
+// WithTransaction executes fn within multi-document transaction
+// fn must use passed session context for all operations to be part of transaction
+// transaction is retried by driver on transient errors, so fn must be safe to re-execute
+// AppError returned by fn is passed through, other errors are wrapped with ErrMongoTransaction
+func (m *MongoStorage) WithTransaction(ctx context.Context, fn func(ctx mongo.SessionContext) error) error {
+
+	session, err := m.Instance.Client().StartSession()
+	if err != nil {
+		return ErrMongoTransaction(err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	if err != nil {
+		if _, ok := er.Is(err); ok {
+			return err
+		}
+		return ErrMongoTransaction(err)
+	}
+
+	return nil
 }
 
 func (m *MongoStorage) Close() {
-	db := m.Instance.Client()
-	_ = db.Disconnect(context.TODO())
+	ctx, cancel := context.WithTimeout(context.Background(), defaultMongoCloseTimeout)
+	defer cancel()
+	_ = m.Instance.Client().Disconnect(ctx)
 }
@@ -0,0 +1,419 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	mongoMigrationsCollection    = "_migrations"
+	mongoMigrationLockCollection = "_migrations_lock"
+	mongoMigrationLockId         = "lock"
+	defaultMongoLockTTL          = time.Minute * 10
+)
+
+// MongoMigrationFunc is a Go-coded step of mongo migration
+type MongoMigrationFunc func(ctx context.Context, db *mongo.Database) error
+
+// MongoIndex defines index created by migration
+type MongoIndex struct {
+	Collection string           // Collection - collection name
+	Model      mongo.IndexModel // Model - index definition
+}
+
+// MongoMigrationDef defines a versioned mongo migration
+// on up indexes are created first, then Up is executed
+// on down Down is executed first, then indexes created on up are dropped
+type MongoMigrationDef struct {
+	Version     int64
+	Description string
+	Indexes     []*MongoIndex
+	Up          MongoMigrationFunc
+	Down        MongoMigrationFunc
+}
+
+// MongoMigrationOptions allows customizing mongo migration
+type MongoMigrationOptions struct {
+	// LockTimeout - how long to wait for lock, waits infinitely if empty
+	LockTimeout time.Duration
+	// LockTTL - lock is considered as stale after TTL (e.g. if owner crashed), 10 min by default
+	// the lock is extended while migration is running, migration fails if the lock is lost
+	LockTTL time.Duration
+}
+
+// mongoMigrationRecord is a document stored in _migrations collection
+type mongoMigrationRecord struct {
+	Version     int64              `bson:"_id"`
+	Description string             `bson:"description"`
+	Indexes     []mongoIndexRecord `bson:"indexes,omitempty"`
+	AppliedAt   time.Time          `bson:"appliedAt"`
+}
+
+type mongoIndexRecord struct {
+	Collection string `bson:"collection"`
+	Name       string `bson:"name"`
+}
+
+// mongoLockRecord is a lock document guarantying single execution across replicas
+type mongoLockRecord struct {
+	Id        string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+type mongoMigImpl struct {
+	db         *mongo.Database
+	migrations []*MongoMigrationDef
+	logger     log.CLoggerFunc
+	opts       *MongoMigrationOptions
+	owner      string
+}
+
+// NewMongoMigration creates a mongo counterpart of goose based Migration
+func NewMongoMigration(storage *MongoStorage, logger log.CLoggerFunc, opts *MongoMigrationOptions, migrations ...*MongoMigrationDef) Migration {
+	// options are copied, so that defaults don't modify caller's ones
+	o := MongoMigrationOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.LockTTL <= 0 {
+		o.LockTTL = defaultMongoLockTTL
+	}
+
+	sorted := make([]*MongoMigrationDef, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	host, _ := os.Hostname()
+
+	return &mongoMigImpl{
+		db:         storage.Instance,
+		migrations: sorted,
+		logger:     logger,
+		opts:       &o,
+		owner:      host + "-" + utils.NewId(),
+	}
+}
+
+func (m *mongoMigImpl) l() log.CLogger {
+	return m.logger().Cmp("mongo-migration")
+}
+
+func (m *mongoMigImpl) Up() error {
+	return m.UpTo(-1)
+}
+
+func (m *mongoMigImpl) UpTo(version int64) error {
+	return m.run("up", func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if version >= 0 && mg.Version > version {
+				break
+			}
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err := m.up(ctx, mg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *mongoMigImpl) Down() error {
+	return m.run("down", func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if rec, ok := applied[m.migrations[i].Version]; ok {
+				return m.down(ctx, m.migrations[i], rec)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *mongoMigImpl) DownTo(version int64) error {
+	return m.run("down-to", func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if mg.Version <= version {
+				break
+			}
+			if rec, ok := applied[mg.Version]; ok {
+				if err := m.down(ctx, mg, rec); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (m *mongoMigImpl) Redo() error {
+	return m.run("redo", func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if rec, ok := applied[m.migrations[i].Version]; ok {
+				if err := m.down(ctx, m.migrations[i], rec); err != nil {
+					return err
+				}
+				return m.up(ctx, m.migrations[i])
+			}
+		}
+		return nil
+	})
+}
+
+func (m *mongoMigImpl) Version() (int64, error) {
+	return m.version(context.Background())
+}
+
+func (m *mongoMigImpl) Status() ([]*MigrationStatus, error) {
+	applied, err := m.applied(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var res []*MigrationStatus
+	for _, mg := range m.migrations {
+		st := &MigrationStatus{
+			Version: mg.Version,
+			Source:  mg.Description,
+		}
+		if rec, ok := applied[mg.Version]; ok {
+			appliedAt := rec.AppliedAt
+			st.Applied = true
+			st.AppliedAt = &appliedAt
+		}
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+// run executes migration command under the lock
+func (m *mongoMigImpl) run(cmd string, fn func(ctx context.Context) error) error {
+	l := m.l().Mth(cmd).Inf("applying ...")
+
+	ctx := context.Background()
+
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := m.unlock(ctx); err != nil {
+			m.l().Mth(cmd).E(err).Err()
+		}
+	}()
+
+	if err := keepLock(ctx, m.opts.LockTTL/3, m.extendLock, fn); err != nil {
+		return err
+	}
+
+	version, err := m.version(ctx)
+	if err != nil {
+		return err
+	}
+	l.InfF("ok, version: %d", version)
+	return nil
+}
+
+func (m *mongoMigImpl) up(ctx context.Context, mg *MongoMigrationDef) error {
+
+	rec := &mongoMigrationRecord{
+		Version:     mg.Version,
+		Description: mg.Description,
+	}
+
+	for _, idx := range mg.Indexes {
+		name, err := m.db.Collection(idx.Collection).Indexes().CreateOne(ctx, idx.Model)
+		if err != nil {
+			return ErrMongoMigrationUp(err, mg.Version)
+		}
+		rec.Indexes = append(rec.Indexes, mongoIndexRecord{Collection: idx.Collection, Name: name})
+	}
+
+	if mg.Up != nil {
+		if err := mg.Up(ctx, m.db); err != nil {
+			return ErrMongoMigrationUp(err, mg.Version)
+		}
+	}
+
+	rec.AppliedAt = time.Now().UTC()
+	if _, err := m.db.Collection(mongoMigrationsCollection).InsertOne(ctx, rec); err != nil {
+		return ErrMongoMigrationUp(err, mg.Version)
+	}
+
+	m.l().Mth("up").F(log.FF{"version": mg.Version}).DbgF("OK %s", mg.Description)
+	return nil
+}
+
+func (m *mongoMigImpl) down(ctx context.Context, mg *MongoMigrationDef, rec *mongoMigrationRecord) error {
+
+	if mg.Down != nil {
+		if err := mg.Down(ctx, m.db); err != nil {
+			return ErrMongoMigrationDown(err, mg.Version)
+		}
+	}
+
+	for _, idx := range rec.Indexes {
+		if _, err := m.db.Collection(idx.Collection).Indexes().DropOne(ctx, idx.Name); err != nil {
+			return ErrMongoMigrationDown(err, mg.Version)
+		}
+	}
+
+	if _, err := m.db.Collection(mongoMigrationsCollection).DeleteOne(ctx, bson.M{"_id": mg.Version}); err != nil {
+		return ErrMongoMigrationDown(err, mg.Version)
+	}
+
+	m.l().Mth("down").F(log.FF{"version": mg.Version}).DbgF("OK %s", mg.Description)
+	return nil
+}
+
+// applied returns applied migrations keyed by version
+func (m *mongoMigImpl) applied(ctx context.Context) (map[int64]*mongoMigrationRecord, error) {
+	cur, err := m.db.Collection(mongoMigrationsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, ErrMongoMigrationGetVer(err)
+	}
+	var recs []*mongoMigrationRecord
+	if err := cur.All(ctx, &recs); err != nil {
+		return nil, ErrMongoMigrationGetVer(err)
+	}
+	res := make(map[int64]*mongoMigrationRecord, len(recs))
+	for _, r := range recs {
+		res[r.Version] = r
+	}
+	return res, nil
+}
+
+// version returns the highest applied version, 0 if nothing applied
+func (m *mongoMigImpl) version(ctx context.Context) (int64, error) {
+	var rec mongoMigrationRecord
+	err := m.db.Collection(mongoMigrationsCollection).FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"_id": -1})).Decode(&rec)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, ErrMongoMigrationGetVer(err)
+	}
+	return rec.Version, nil
+}
+
+// lock inserts lock document, so that only one replica executes migration
+// if lock document exists and is expired, it's taken over
+func (m *mongoMigImpl) lock(ctx context.Context) error {
+
+	coll := m.db.Collection(mongoMigrationLockCollection)
+
+	var deadline time.Time
+	if m.opts.LockTimeout > 0 {
+		deadline = time.Now().Add(m.opts.LockTimeout)
+	}
+
+	for {
+		now := time.Now().UTC()
+		_, err := coll.InsertOne(ctx, &mongoLockRecord{
+			Id:        mongoMigrationLockId,
+			Owner:     m.owner,
+			ExpiresAt: now.Add(m.opts.LockTTL),
+		})
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return ErrMongoMigrationLock(err)
+		}
+
+		// remove stale lock
+		if _, err := coll.DeleteOne(ctx, bson.M{"_id": mongoMigrationLockId, "expiresAt": bson.M{"$lt": now}}); err != nil {
+			return ErrMongoMigrationLock(err)
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return ErrMongoMigrationLockTimeout(m.opts.LockTimeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// extendLock prolongs the lock owned by the migration
+func (m *mongoMigImpl) extendLock(ctx context.Context) error {
+	rs, err := m.db.Collection(mongoMigrationLockCollection).UpdateOne(ctx,
+		bson.M{"_id": mongoMigrationLockId, "owner": m.owner},
+		bson.M{"$set": bson.M{"expiresAt": time.Now().UTC().Add(m.opts.LockTTL)}})
+	if err != nil {
+		return err
+	}
+	if rs.MatchedCount == 0 {
+		return fmt.Errorf("lock is taken over by another owner")
+	}
+	return nil
+}
+
+// keepLock executes fn extending the lock each interval
+// if the lock cannot be extended, context of fn is cancelled and error is returned
+func keepLock(ctx context.Context, interval time.Duration, extend func(ctx context.Context) error, fn func(ctx context.Context) error) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lost := make(chan error, 1)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := extend(ctx); err != nil {
+					lost <- err
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err := fn(ctx)
+	close(done)
+	<-stopped
+
+	select {
+	case e := <-lost:
+		return ErrMongoMigrationLockLost(e)
+	default:
+		return err
+	}
+}
+
+func (m *mongoMigImpl) unlock(ctx context.Context) error {
+	if _, err := m.db.Collection(mongoMigrationLockCollection).DeleteOne(ctx, bson.M{"_id": mongoMigrationLockId, "owner": m.owner}); err != nil {
+		return ErrMongoMigrationUnLock(err)
+	}
+	return nil
+}
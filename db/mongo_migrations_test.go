@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_KeepLock(t *testing.T) {
+	var extended int32
+	extend := func(ctx context.Context) error {
+		atomic.AddInt32(&extended, 1)
+		return nil
+	}
+	err := keepLock(context.Background(), time.Millisecond, extend, func(ctx context.Context) error {
+		time.Sleep(time.Millisecond * 20)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&extended) == 0 {
+		t.Fatal("lock must be extended while migration is running")
+	}
+
+	// lost lock cancels migration
+	extend = func(ctx context.Context) error { return errors.New("lock is taken over") }
+	err = keepLock(context.Background(), time.Millisecond, extend, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			t.Fatal("migration isn't cancelled")
+			return nil
+		}
+	})
+	assertCode(t, err, ErrCodeMongoLockLost)
+}
+
+func Test_NewMongoMigrationOptions(t *testing.T) {
+	opts := &MongoMigrationOptions{LockTimeout: time.Second}
+	m := NewMongoMigration(&MongoStorage{}, nil, opts).(*mongoMigImpl)
+	if opts.LockTTL != 0 {
+		t.Fatal("caller's options mustn't be modified")
+	}
+	if m.opts.LockTTL != defaultMongoLockTTL || m.opts.LockTimeout != time.Second {
+		t.Fatalf("unexpected options %+v", m.opts)
+	}
+}
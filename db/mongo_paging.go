@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+
+	"git.jetbrains.space/orbi/fcsd/kit/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoPaginate builds find options applying offset paging and sorting
+// sort fields are validated against whitelist, if no sorting is requested documents are sorted by _id
+func MongoPaginate(ctx context.Context, rq *common.PagingRequest, fields SortFields) (*options.FindOptions, error) {
+
+	var sortBy []*common.SortRequest
+	if rq != nil {
+		sortBy = rq.SortBy
+	}
+	cols, err := sortColumns(ctx, sortBy, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	sort := bson.D{}
+	for _, c := range cols {
+		dir := 1
+		if c.Desc {
+			dir = -1
+		}
+		sort = append(sort, bson.E{Key: c.Column, Value: dir})
+	}
+	if len(sort) == 0 {
+		sort = append(sort, bson.E{Key: "_id", Value: 1})
+	}
+
+	opts := options.Find()
+	opts.SetSort(sort)
+
+	size := pageSize(rq)
+	opts.SetSkip(int64((pageIndex(rq) - 1) * size))
+	opts.SetLimit(int64(size))
+
+	return opts, nil
+}
+
+// MongoFindPage counts all documents matching filter and finds the requested page into dest
+// dest must be a pointer to slice
+// only offset pagination is supported for mongo, PagingRequest.Cursor is ignored (there is no mongo counterpart of FindKeyset)
+func MongoFindPage(ctx context.Context, coll *mongo.Collection, filter interface{}, rq *common.PagingRequest, fields SortFields, dest interface{}) (*common.PagingResponse, error) {
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	opts, err := MongoPaginate(ctx, rq, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, ErrPagingCount(err)
+	}
+
+	cur, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, ErrPagingFind(err)
+	}
+	if err := cur.All(ctx, dest); err != nil {
+		return nil, ErrPagingFind(err)
+	}
+
+	return &common.PagingResponse{
+		Total: int(total),
+		Index: pageIndex(rq),
+		Size:  pageSize(rq),
+	}, nil
+}
@@ -0,0 +1,359 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/common"
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// SortFields is a whitelist of fields allowed for sorting
+// key is a field name passed in SortRequest.Field, value is a db column (or mongo field) name
+type SortFields map[string]string
+
+// sortColumn is a validated sort request
+type sortColumn struct {
+	Column string
+	Desc   bool
+}
+
+// sortColumns validates sort request against whitelist
+func sortColumns(ctx context.Context, rq []*common.SortRequest, fields SortFields) ([]*sortColumn, error) {
+	var res []*sortColumn
+	for _, s := range rq {
+		if s == nil {
+			continue
+		}
+		col, ok := fields[s.Field]
+		if !ok {
+			return nil, ErrPagingSortField(ctx, s.Field)
+		}
+		sc := &sortColumn{Column: col}
+		switch strings.ToLower(s.Direction) {
+		case "", common.SortAsc:
+		case common.SortDesc:
+			sc.Desc = true
+		default:
+			return nil, ErrPagingSortDirection(ctx, s.Direction)
+		}
+		res = append(res, sc)
+	}
+	return res, nil
+}
+
+// pageSize returns size bounded by default and max values
+func pageSize(rq *common.PagingRequest) int {
+	if rq == nil || rq.Size <= 0 {
+		return DefaultPageSize
+	}
+	if rq.Size > MaxPageSize {
+		return MaxPageSize
+	}
+	return rq.Size
+}
+
+// pageIndex returns page number starting from 1
+func pageIndex(rq *common.PagingRequest) int {
+	if rq == nil || rq.Index <= 0 {
+		return 1
+	}
+	return rq.Index
+}
+
+// Paginate is a gorm scope applying offset paging and sorting
+// sort fields are validated against whitelist, if validation fails error is added to the query
+// if no sorting is requested, items are sorted by primary key of the model, so that pages are stable
+func Paginate(ctx context.Context, rq *common.PagingRequest, fields SortFields) func(*gorm.DB) *gorm.DB {
+	return func(q *gorm.DB) *gorm.DB {
+		var sortBy []*common.SortRequest
+		if rq != nil {
+			sortBy = rq.SortBy
+		}
+		cols, err := sortColumns(ctx, sortBy, fields)
+		if err != nil {
+			_ = q.AddError(err)
+			return q
+		}
+		if len(cols) == 0 {
+			q = q.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}})
+		}
+		for _, c := range cols {
+			q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: c.Column}, Desc: c.Desc})
+		}
+		size := pageSize(rq)
+		return q.Offset((pageIndex(rq) - 1) * size).Limit(size)
+	}
+}
+
+// FindPage counts all items matching query and finds the requested page into dest
+// query must have model or table specified, dest must be a pointer to slice
+func FindPage(ctx context.Context, q *gorm.DB, rq *common.PagingRequest, fields SortFields, dest interface{}) (*common.PagingResponse, error) {
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, ErrPagingCount(err)
+	}
+
+	if err := q.Session(&gorm.Session{}).Scopes(Paginate(ctx, rq, fields)).Find(dest).Error; err != nil {
+		if _, ok := er.Is(err); ok {
+			return nil, err
+		}
+		return nil, ErrPagingFind(err)
+	}
+
+	return &common.PagingResponse{
+		Total: int(total),
+		Index: pageIndex(rq),
+		Size:  pageSize(rq),
+	}, nil
+}
+
+// keysetCursor is a payload of opaque cursor token
+type keysetCursor struct {
+	// Sort - signature of sorting the cursor was built for
+	Sort string `json:"s"`
+	// Values - sort column values of the last item
+	Values []*cursorValue `json:"v"`
+}
+
+// cursorValue keeps type of value, so that it's restored properly after JSON decoding
+type cursorValue struct {
+	Kind  string          `json:"k"`
+	Value json.RawMessage `json:"v"`
+}
+
+const (
+	cursorKindTime   = "t"
+	cursorKindInt    = "i"
+	cursorKindUint   = "u"
+	cursorKindFloat  = "f"
+	cursorKindBool   = "b"
+	cursorKindString = "s"
+)
+
+// FindKeyset finds the requested page into dest using keyset (cursor) pagination
+// it doesn't use OFFSET and doesn't count total, so it's suitable for large tables
+// tieBreaker is a unique column (usually primary key) added to sorting to make order deterministic
+// sort columns and tieBreaker must be non-nullable fields of the model of dest
+// NULL can't be compared in keyset condition, so pointer fields (and other types not supported in cursor) are rejected
+func FindKeyset(ctx context.Context, q *gorm.DB, rq *common.PagingRequest, fields SortFields, tieBreaker string, dest interface{}) (*common.PagingResponse, error) {
+
+	var sortBy []*common.SortRequest
+	var cursor string
+	if rq != nil {
+		sortBy = rq.SortBy
+		cursor = rq.Cursor
+	}
+
+	cols, err := sortColumns(ctx, sortBy, fields)
+	if err != nil {
+		return nil, err
+	}
+	hasTieBreaker := false
+	for _, c := range cols {
+		if c.Column == tieBreaker {
+			hasTieBreaker = true
+		}
+	}
+	if !hasTieBreaker {
+		cols = append(cols, &sortColumn{Column: tieBreaker})
+	}
+	signature := sortSignature(cols)
+
+	// values of the last item are put to the cursor, so all columns must be non-nullable fields of the model
+	stmt := &gorm.Statement{DB: q}
+	if err := stmt.Parse(dest); err != nil {
+		return nil, ErrPagingFind(err)
+	}
+	fieldsOf := make([]*schema.Field, 0, len(cols))
+	for _, c := range cols {
+		f := stmt.Schema.LookUpField(c.Column)
+		if f == nil || cursorKind(f.FieldType) == "" {
+			return nil, ErrPagingSortField(ctx, c.Column)
+		}
+		fieldsOf = append(fieldsOf, f)
+	}
+
+	q = q.Session(&gorm.Session{})
+
+	if cursor != "" {
+		values, err := decodeCursor(ctx, cursor, signature, len(cols))
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(keysetCondition(cols, values))
+	}
+
+	for _, c := range cols {
+		q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: c.Column}, Desc: c.Desc})
+	}
+
+	size := pageSize(rq)
+
+	// one extra item is requested to find out if there is a next page
+	if err := q.Limit(size + 1).Find(dest).Error; err != nil {
+		return nil, ErrPagingFind(err)
+	}
+
+	rs := &common.PagingResponse{Size: size}
+
+	items := reflect.Indirect(reflect.ValueOf(dest))
+	if items.Kind() != reflect.Slice || items.Len() <= size {
+		return rs, nil
+	}
+
+	// cut extra item
+	items.Set(items.Slice(0, size))
+
+	last := reflect.Indirect(items.Index(size - 1))
+	var values []interface{}
+	for _, f := range fieldsOf {
+		values = append(values, f.ReflectValueOf(last).Interface())
+	}
+
+	rs.NextCursor, err = encodeCursor(signature, values)
+	if err != nil {
+		return nil, ErrPagingCursor(ctx, err)
+	}
+
+	return rs, nil
+}
+
+// keysetCondition builds condition selecting rows after the cursor
+// e.g. for (a asc, b desc): a > ? OR (a = ? AND b < ?)
+func keysetCondition(cols []*sortColumn, values []interface{}) clause.Expression {
+	var ors []clause.Expression
+	for i, c := range cols {
+		var ands []clause.Expression
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: clause.Column{Name: cols[j].Column}, Value: values[j]})
+		}
+		if c.Desc {
+			ands = append(ands, clause.Lt{Column: clause.Column{Name: c.Column}, Value: values[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: clause.Column{Name: c.Column}, Value: values[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	return clause.Or(ors...)
+}
+
+func sortSignature(cols []*sortColumn) string {
+	var parts []string
+	for _, c := range cols {
+		if c.Desc {
+			parts = append(parts, "-"+c.Column)
+		} else {
+			parts = append(parts, c.Column)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(signature string, values []interface{}) (string, error) {
+	c := &keysetCursor{Sort: signature}
+	for _, v := range values {
+		var kind string
+		if v != nil {
+			kind = cursorKind(reflect.TypeOf(v))
+		}
+		if kind == "" {
+			return "", fmt.Errorf("type %T isn't supported in cursor", v)
+		}
+		js, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, &cursorValue{Kind: kind, Value: js})
+	}
+	js, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(js), nil
+}
+
+// cursorKind returns kind of cursor value for type t or empty string if type isn't supported
+func cursorKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cursorKindInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cursorKindUint
+	case reflect.Float32, reflect.Float64:
+		return cursorKindFloat
+	case reflect.Bool:
+		return cursorKindBool
+	case reflect.String:
+		return cursorKindString
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return cursorKindTime
+	}
+	return ""
+}
+
+func decodeCursor(ctx context.Context, cursor, signature string, n int) ([]interface{}, error) {
+	js, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrPagingCursor(ctx, err)
+	}
+	var c keysetCursor
+	if err := json.Unmarshal(js, &c); err != nil {
+		return nil, ErrPagingCursor(ctx, err)
+	}
+	if c.Sort != signature || len(c.Values) != n {
+		return nil, ErrPagingCursor(ctx, fmt.Errorf("cursor doesn't match sorting"))
+	}
+	var res []interface{}
+	for _, cv := range c.Values {
+		var v interface{}
+		switch cv.Kind {
+		case cursorKindTime:
+			var t time.Time
+			err = json.Unmarshal(cv.Value, &t)
+			v = t
+		case cursorKindInt:
+			var i int64
+			err = json.Unmarshal(cv.Value, &i)
+			v = i
+		case cursorKindUint:
+			var u uint64
+			err = json.Unmarshal(cv.Value, &u)
+			v = u
+		case cursorKindFloat:
+			var f float64
+			err = json.Unmarshal(cv.Value, &f)
+			v = f
+		case cursorKindBool:
+			var b bool
+			err = json.Unmarshal(cv.Value, &b)
+			v = b
+		case cursorKindString:
+			var s string
+			err = json.Unmarshal(cv.Value, &s)
+			v = s
+		default:
+			err = fmt.Errorf("unknown cursor value kind %s", cv.Kind)
+		}
+		if err != nil {
+			return nil, ErrPagingCursor(ctx, err)
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
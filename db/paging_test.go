@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/common"
+)
+
+type pagingItem struct {
+	Id        string
+	Amount    int64
+	CreatedAt time.Time
+	DeletedAt *time.Time
+}
+
+var pagingSortFields = SortFields{"amount": "amount", "createdAt": "created_at", "deletedAt": "deleted_at", "secret": "secret"}
+
+func Test_Cursor(t *testing.T) {
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC)
+	values := []interface{}{ts, int32(-1), uint(2), 1.5, true, "str"}
+
+	cursor, err := encodeCursor("a,-b", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := decodeCursor(context.Background(), cursor, "a,-b", len(values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res[0].(time.Time).Equal(ts) || res[1] != int64(-1) || res[2] != uint64(2) || res[3] != 1.5 || res[4] != true || res[5] != "str" {
+		t.Fatalf("unexpected values %v", res)
+	}
+
+	// cursor of another sorting
+	_, err = decodeCursor(context.Background(), cursor, "a,b", len(values))
+	assertCode(t, err, ErrCodePagingCursor)
+	_, err = decodeCursor(context.Background(), "!", "a,-b", 1)
+	assertCode(t, err, ErrCodePagingCursor)
+
+	s := "str"
+	for _, v := range []interface{}{struct{}{}, &s, (*string)(nil), nil} {
+		if _, err := encodeCursor("a", []interface{}{v}); err == nil {
+			t.Fatalf("unsupported type %T must fail", v)
+		}
+	}
+}
+
+func Test_KeysetCondition(t *testing.T) {
+	cols := []*sortColumn{{Column: "amount", Desc: true}, {Column: "id"}}
+	stmt := dryRun(t).Model(&pagingItem{}).Where(keysetCondition(cols, []interface{}{int64(10), "a"})).Find(&[]*pagingItem{}).Statement
+	expected := `("amount" < $1 OR ("amount" = $2 AND "id" > $3))`
+	if !strings.Contains(stmt.SQL.String(), expected) {
+		t.Fatalf("unexpected sql %s", stmt.SQL.String())
+	}
+}
+
+func Test_FindKeyset(t *testing.T) {
+	db := dryRun(t)
+	ctx := context.Background()
+
+	var items []*pagingItem
+	rq := &common.PagingRequest{Size: 10, SortBy: []*common.SortRequest{{Field: "createdAt", Direction: common.SortDesc}}}
+	rs, err := FindKeyset(ctx, db.Model(&pagingItem{}), rq, pagingSortFields, "id", &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Size != 10 || rs.NextCursor != "" {
+		t.Fatalf("unexpected response %+v", rs)
+	}
+
+	// cursor of another sorting
+	cursor, _ := encodeCursor("amount,id", []interface{}{int64(1), "a"})
+	rq.Cursor = cursor
+	_, err = FindKeyset(ctx, db.Model(&pagingItem{}), rq, pagingSortFields, "id", &items)
+	assertCode(t, err, ErrCodePagingCursor)
+
+	// whitelisted field and tie breaker must be fields of the model
+	_, err = FindKeyset(ctx, db.Model(&pagingItem{}), &common.PagingRequest{SortBy: []*common.SortRequest{{Field: "secret"}}}, pagingSortFields, "id", &items)
+	assertCode(t, err, ErrCodePagingSortField)
+	_, err = FindKeyset(ctx, db.Model(&pagingItem{}), nil, pagingSortFields, "uuid", &items)
+	assertCode(t, err, ErrCodePagingSortField)
+	_, err = FindKeyset(ctx, db.Model(&pagingItem{}), &common.PagingRequest{SortBy: []*common.SortRequest{{Field: "unknown"}}}, pagingSortFields, "id", &items)
+	assertCode(t, err, ErrCodePagingSortField)
+
+	// nullable field is rejected before query
+	_, err = FindKeyset(ctx, db.Model(&pagingItem{}), &common.PagingRequest{SortBy: []*common.SortRequest{{Field: "deletedAt"}}}, pagingSortFields, "id", &items)
+	assertCode(t, err, ErrCodePagingSortField)
+}
+
+func Test_Paginate(t *testing.T) {
+	db := dryRun(t)
+	ctx := context.Background()
+
+	// primary key by default
+	stmt := db.Model(&pagingItem{}).Scopes(Paginate(ctx, nil, pagingSortFields)).Find(&[]*pagingItem{}).Statement
+	if !strings.Contains(stmt.SQL.String(), `ORDER BY "paging_items"."id" LIMIT 50`) {
+		t.Fatalf("unexpected sql %s", stmt.SQL.String())
+	}
+
+	rq := &common.PagingRequest{Index: 2, Size: 10, SortBy: []*common.SortRequest{{Field: "amount", Direction: common.SortDesc}}}
+	stmt = db.Model(&pagingItem{}).Scopes(Paginate(ctx, rq, pagingSortFields)).Find(&[]*pagingItem{}).Statement
+	if !strings.Contains(stmt.SQL.String(), `ORDER BY "amount" DESC LIMIT 10 OFFSET 10`) {
+		t.Fatalf("unexpected sql %s", stmt.SQL.String())
+	}
+}
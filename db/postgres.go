@@ -2,17 +2,25 @@ package db
 
 import (
 	"fmt"
+	"strings"
+	"time"
+
 	kitLog "git.jetbrains.space/orbi/fcsd/kit/log"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	gormLogger "gorm.io/gorm/logger"
-	"time"
+)
+
+const (
+	defaultSslMode  = "disable"
+	defaultTimeZone = "Europe/Moscow"
 )
 
 type Storage struct {
-	Instance *gorm.DB
-	DBName   string
-	logger   kitLog.CLoggerFunc
+	Instance  *gorm.DB
+	DBName    string
+	logger    kitLog.CLoggerFunc
+	txRetries int
+	tenancy   *TenancyConfig
 }
 
 // DbClusterConfig configuration of database cluster
@@ -29,41 +37,150 @@ type DbConfig struct {
 	DBName   string
 	Port     string
 	Host     string
+	// SslMode - postgres sslmode (disable, require, verify-ca, verify-full), "disable" by default
+	SslMode string `config:"ssl-mode"`
+	// SslCert - path to client certificate
+	SslCert string `config:"ssl-cert"`
+	// SslKey - path to client private key
+	SslKey string `config:"ssl-key"`
+	// SslRootCert - path to CA certificate used to verify server
+	SslRootCert string `config:"ssl-root-cert"`
+	// TimeZone - session time zone, "Europe/Moscow" by default
+	TimeZone string `config:"time-zone"`
+	// AppName - application_name reported to the server
+	AppName string `config:"app-name"`
+	// StatementTimeout - aborts statements taking longer, no timeout if empty
+	StatementTimeout time.Duration `config:"statement-timeout"`
+	// MaxOpenConns - max number of open connections, unlimited if empty
+	MaxOpenConns int `config:"max-open-conns"`
+	// MaxIdleConns - max number of idle connections
+	MaxIdleConns int `config:"max-idle-conns"`
+	// ConnMaxLifetime - max amount of time a connection may be reused
+	ConnMaxLifetime time.Duration `config:"conn-max-lifetime"`
+	// ConnMaxIdleTime - max amount of time a connection may be idle
+	ConnMaxIdleTime time.Duration `config:"conn-max-idle-time"`
+	// TxRetries - how many times transaction is retried on serialization failure or deadlock, 3 by default
+	TxRetries int `config:"tx-retries"`
+	// Log - gorm logging configuration
+	Log *GormLogConfig
+	// Audit - auditing and optimistic locking configuration, CreatedBy / UpdatedBy / Version aren't maintained if empty
+	Audit *AuditConfig
+	// Metrics - if true, query durations are collected
+	Metrics bool `config:"metrics"`
+	// Tracing - if true, a span is created per query (see tracing.Init)
+	Tracing bool `config:"tracing"`
+	// Tenancy - tenant isolation configuration, tenants aren't isolated if empty
+	Tenancy *TenancyConfig `config:"tenancy"`
+}
+
+// Dsn builds postgres connection string
+func (c *DbConfig) Dsn() string {
+
+	sslMode := c.SslMode
+	if sslMode == "" {
+		sslMode = defaultSslMode
+	}
+	timeZone := c.TimeZone
+	if timeZone == "" {
+		timeZone = defaultTimeZone
+	}
+
+	params := [][2]string{
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", c.DBName},
+		{"port", c.Port},
+		{"host", c.Host},
+		{"sslmode", sslMode},
+		{"sslcert", c.SslCert},
+		{"sslkey", c.SslKey},
+		{"sslrootcert", c.SslRootCert},
+		{"TimeZone", timeZone},
+		{"application_name", c.AppName},
+	}
+	if c.StatementTimeout > 0 {
+		params = append(params, [2]string{"statement_timeout", fmt.Sprint(c.StatementTimeout.Milliseconds())})
+	}
+
+	var parts []string
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", p[0], dsnValue(p[1])))
+	}
+	return strings.Join(parts, " ")
+}
+
+// dsnValue quotes value if it contains characters not allowed in key/value connection string
+func dsnValue(v string) string {
+	if !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
 }
 
 func Open(config *DbConfig, logger kitLog.CLoggerFunc) (*Storage, error) {
 
 	s := &Storage{
-		DBName: config.DBName,
-		logger: logger,
+		DBName:    config.DBName,
+		logger:    logger,
+		txRetries: config.TxRetries,
+		tenancy:   config.Tenancy,
 	}
 
-	dsn := fmt.Sprintf("user=%s password=%s dbname=%s port=%s host=%s sslmode=disable TimeZone=Europe/Moscow",
-		config.User,
-		config.Password,
-		config.DBName,
-		config.Port,
-		config.Host,
-	)
-
-	// uncomment to log all queries
 	cfg := &gorm.Config{
-		Logger: gormLogger.New(
-			logger(),
-			//log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-			gormLogger.Config{
-				SlowThreshold: time.Second * 10, // Slow SQL threshold
-				LogLevel:      gormLogger.Info,  // Log level
-				Colorful:      false,            // Disable color
-			},
-		),
+		Logger:  NewGormLogger(logger, config.Log),
 		NowFunc: func() time.Time { return time.Now() },
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), cfg)
+	db, err := gorm.Open(postgres.Open(config.Dsn()), cfg)
+	if err != nil {
+		return nil, ErrPostgresOpen(err)
+	}
+
+	if config.Audit != nil {
+		if err := RegisterCallbacks(db, config.Audit); err != nil {
+			return nil, ErrRegisterCallbacks(err)
+		}
+	}
+
+	if config.Tenancy != nil {
+		if err := RegisterTenancy(db, config.Tenancy); err != nil {
+			return nil, ErrRegisterCallbacks(err)
+		}
+	}
+
+	if config.Metrics {
+		if err := RegisterMetrics(db); err != nil {
+			return nil, ErrRegisterCallbacks(err)
+		}
+	}
+
+	if config.Tracing {
+		if err := RegisterTracing(db); err != nil {
+			return nil, ErrRegisterCallbacks(err)
+		}
+	}
+
+	sqlDb, err := db.DB()
 	if err != nil {
 		return nil, ErrPostgresOpen(err)
 	}
+	if config.MaxOpenConns > 0 {
+		sqlDb.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		sqlDb.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		sqlDb.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	if config.ConnMaxIdleTime > 0 {
+		sqlDb.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
 
 	logger().Pr("db").Cmp(config.User).Inf("ok")
 
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	fieldTenantId = "TenantId"
+
+	tenancySkipKey = "kit:tenancy_skip"
+
+	defaultTenantSchemaPrefix = "tenant_"
+)
+
+// TenantDto is embedded into models belonging to a tenant
+type TenantDto struct {
+	TenantId string `gorm:"index;not null"`
+}
+
+// TenancyConfig configures tenant isolation
+type TenancyConfig struct {
+	// SchemaPerTenant - if true, tables are qualified with the schema of the request context tenant (postgres only)
+	SchemaPerTenant bool `config:"schema-per-tenant"`
+	// SchemaPrefix - prefix of tenant schema names, "tenant_" by default
+	SchemaPrefix string `config:"schema-prefix"`
+	// SharedTables - tables not qualified with tenant schema (e.g. registry of tenants)
+	SharedTables []string `config:"shared-tables"`
+}
+
+// Schema returns name of the tenant schema
+func (c *TenancyConfig) Schema(tenantId string) string {
+	prefix := c.SchemaPrefix
+	if prefix == "" {
+		prefix = defaultTenantSchemaPrefix
+	}
+	return prefix + tenantId
+}
+
+// RegisterTenancy registers gorm callbacks isolating tenants by tenant id of the request context
+// models having TenantId field (see TenantDto) are filtered by the tenant on read, update and delete and created with it,
+// such statements fail with ErrTenantMissing if there is no tenant and with ErrTenantMismatch if a model of another tenant is written
+// raw SQL isn't affected, WithoutTenancy disables isolation for cross-tenant statements (e.g. background jobs)
+func RegisterTenancy(db *gorm.DB, cfg *TenancyConfig) error {
+
+	if cfg == nil {
+		cfg = &TenancyConfig{}
+	}
+	cb := db.Callback()
+
+	if err := cb.Create().Before("gorm:create").Register("kit:tenancy_create", tenancyCreate(cfg)); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("kit:tenancy_query", tenancyFilter(cfg)); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("kit:tenancy_update", tenancyUpdate(cfg)); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("kit:tenancy_delete", tenancyFilter(cfg)); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("kit:tenancy_row", tenancyFilter(cfg)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WithoutTenancy returns session not isolated by tenant
+func WithoutTenancy(db *gorm.DB) *gorm.DB {
+	return db.Set(tenancySkipKey, true)
+}
+
+// CreateTenantSchema creates schema of the tenant if it doesn't exist, tables are created by service migrations
+func (s *Storage) CreateTenantSchema(ctx context.Context, tenantId string) error {
+	if !kitContext.IsValidTenantId(tenantId) {
+		return ErrTenantInvalid(ctx, tenantId)
+	}
+	cfg := s.tenancy
+	if cfg == nil {
+		cfg = &TenancyConfig{}
+	}
+	if err := s.Instance.WithContext(ctx).Exec("CREATE SCHEMA IF NOT EXISTS ?", clause.Table{Name: cfg.Schema(tenantId)}).Error; err != nil {
+		return ErrTenantSchema(err, tenantId)
+	}
+	return nil
+}
+
+// tenancyPrepare qualifies table with tenant schema and returns tenant field of the model and tenant id
+// nil field means the statement isn't isolated
+func tenancyPrepare(db *gorm.DB, cfg *TenancyConfig) (*schema.Field, string, bool) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil, "", false
+	}
+	if _, ok := db.Get(tenancySkipKey); ok {
+		return nil, "", false
+	}
+
+	tenantId, _ := kitContext.Tenant(db.Statement.Context)
+	f := db.Statement.Schema.LookUpField(fieldTenantId)
+
+	if cfg.SchemaPerTenant && !strings.Contains(db.Statement.Table, ".") && !contains(cfg.SharedTables, db.Statement.Table) {
+		if tenantId == "" {
+			_ = db.AddError(ErrTenantMissing(db.Statement.Context, db.Statement.Table))
+			return nil, "", false
+		}
+		db.Statement.Table = cfg.Schema(tenantId) + "." + db.Statement.Table
+	}
+
+	if f == nil {
+		return nil, "", false
+	}
+	if tenantId == "" {
+		_ = db.AddError(ErrTenantMissing(db.Statement.Context, db.Statement.Table))
+		return nil, "", false
+	}
+	return f, tenantId, true
+}
+
+func tenancyCreate(cfg *TenancyConfig) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		f, tenantId, ok := tenancyPrepare(db, cfg)
+		if !ok {
+			return
+		}
+		if tenantConflict(f, db.Statement.ReflectValue, tenantId) || tenantConflict(f, reflect.ValueOf(db.Statement.Dest), tenantId) {
+			_ = db.AddError(ErrTenantMismatch(db.Statement.Context, db.Statement.Table))
+			return
+		}
+		db.Statement.SetColumn(f.Name, tenantId, true)
+	}
+}
+
+func tenancyUpdate(cfg *TenancyConfig) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		f, tenantId, ok := tenancyPrepare(db, cfg)
+		if !ok {
+			return
+		}
+		// models can't be moved to another tenant
+		if tenantConflict(f, db.Statement.ReflectValue, tenantId) || tenantConflict(f, reflect.ValueOf(db.Statement.Dest), tenantId) {
+			_ = db.AddError(ErrTenantMismatch(db.Statement.Context, db.Statement.Table))
+			return
+		}
+		// Save writes all fields, so empty tenant id is populated
+		if db.Statement.ReflectValue.Kind() == reflect.Struct {
+			if _, zero := f.ValueOf(db.Statement.ReflectValue); zero {
+				db.Statement.SetColumn(f.Name, tenantId, true)
+			}
+		}
+		addTenantClause(db, f, tenantId)
+	}
+}
+
+func tenancyFilter(cfg *TenancyConfig) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if f, tenantId, ok := tenancyPrepare(db, cfg); ok {
+			addTenantClause(db, f, tenantId)
+		}
+	}
+}
+
+func addTenantClause(db *gorm.DB, f *schema.Field, tenantId string) {
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: tenantId},
+	}})
+}
+
+// tenantConflict checks if the value (model, slice of models or map) has tenant id other than the tenant
+func tenantConflict(f *schema.Field, v reflect.Value, tenantId string) bool {
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if tenantConflict(f, v.Index(i), tenantId) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		if v.Type() == f.Schema.ModelType {
+			if tid, zero := f.ValueOf(v); !zero && tid != tenantId {
+				return true
+			}
+		}
+	case reflect.Map:
+		if m, ok := v.Interface().(map[string]interface{}); ok {
+			for _, k := range []string{f.Name, f.DBName} {
+				if tid, ok := m[k]; ok && tid != tenantId {
+					return true
+				}
+			}
+		}
+	case reflect.Interface:
+		return tenantConflict(f, v.Elem(), tenantId)
+	}
+	return false
+}
+
+func contains(s []string, v string) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
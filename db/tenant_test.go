@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"gorm.io/gorm"
+)
+
+type tenantItem struct {
+	Id   string
+	Name string
+	TenantDto
+}
+
+func dryRunDb(t *testing.T, cfg *TenancyConfig) *gorm.DB {
+	db := dryRun(t)
+	if err := RegisterTenancy(db, cfg); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func tenantCtx(tenantId string) context.Context {
+	return kitContext.NewRequestCtx().WithNewRequestId().WithTenant(tenantId).ToContext(context.Background())
+}
+
+func Test_Tenancy_Filter(t *testing.T) {
+	db := dryRunDb(t, nil)
+
+	stmt := db.WithContext(tenantCtx("a")).Where("name = ?", "n").Find(&[]*tenantItem{}).Statement
+	if !strings.Contains(stmt.SQL.String(), `"tenant_items"."tenant_id" = $`) || stmt.Vars[len(stmt.Vars)-1] != "a" {
+		t.Fatalf("tenant filter expected: %s %v", stmt.SQL.String(), stmt.Vars)
+	}
+
+	stmt = db.WithContext(tenantCtx("a")).Model(&tenantItem{Id: "1"}).Update("name", "n").Statement
+	if !strings.Contains(stmt.SQL.String(), `"tenant_id" = $`) {
+		t.Fatalf("tenant filter expected: %s", stmt.SQL.String())
+	}
+
+	// no tenant
+	assertCode(t, db.WithContext(context.Background()).Find(&[]*tenantItem{}).Error, ErrCodeTenantMissing)
+
+	// isolation disabled
+	stmt = WithoutTenancy(db.WithContext(context.Background())).Find(&[]*tenantItem{}).Statement
+	if stmt.Error != nil || strings.Contains(stmt.SQL.String(), "tenant_id") {
+		t.Fatalf("tenant filter isn't expected: %s %v", stmt.SQL.String(), stmt.Error)
+	}
+}
+
+func Test_Tenancy_CrossTenantWrite(t *testing.T) {
+	db := dryRunDb(t, nil)
+	ctx := tenantCtx("a")
+
+	item := &tenantItem{Id: "1"}
+	if err := db.WithContext(ctx).Create(item).Error; err != nil || item.TenantId != "a" {
+		t.Fatalf("tenant must be populated %v %v", item.TenantId, err)
+	}
+
+	assertCode(t, db.WithContext(ctx).Create(&tenantItem{Id: "2", TenantDto: TenantDto{TenantId: "b"}}).Error, ErrCodeTenantMismatch)
+	assertCode(t, db.WithContext(ctx).Create([]*tenantItem{{Id: "3"}, {Id: "4", TenantDto: TenantDto{TenantId: "b"}}}).Error, ErrCodeTenantMismatch)
+	assertCode(t, db.WithContext(ctx).Save(&tenantItem{Id: "1", TenantDto: TenantDto{TenantId: "b"}}).Error, ErrCodeTenantMismatch)
+	assertCode(t, db.WithContext(ctx).Model(&tenantItem{Id: "1"}).Updates(map[string]interface{}{"tenant_id": "b"}).Error, ErrCodeTenantMismatch)
+}
+
+func Test_Tenancy_SchemaPerTenant(t *testing.T) {
+	db := dryRunDb(t, &TenancyConfig{SchemaPerTenant: true, SharedTables: []string{"tenants"}})
+
+	stmt := db.WithContext(tenantCtx("a")).Find(&[]*tenantItem{}).Statement
+	if !strings.Contains(stmt.SQL.String(), `FROM "tenant_a"."tenant_items"`) {
+		t.Fatalf("tenant schema expected: %s", stmt.SQL.String())
+	}
+
+	stmt = db.WithContext(tenantCtx("a")).Table("tenants").Find(&[]map[string]interface{}{}).Statement
+	if !strings.Contains(stmt.SQL.String(), `FROM "tenants"`) {
+		t.Fatalf("shared table expected: %s", stmt.SQL.String())
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"git.jetbrains.space/orbi/fcsd/kit/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const tracingSpanKey = "kit:tracing_span"
+
+// RegisterTracing registers gorm callbacks creating a span per query
+// span is a child of the span found in the statement context (see gorm.DB.WithContext)
+func RegisterTracing(db *gorm.DB) error {
+
+	cb := db.Callback()
+
+	registers := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+
+	for _, r := range registers {
+		if err := r.before("kit:tracing_before_"+r.operation, tracingBefore(r.operation)); err != nil {
+			return err
+		}
+		if err := r.after("kit:tracing_after_"+r.operation, tracingAfter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func tracingBefore(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		_, span := tracing.Start(db.Statement.Context, "db "+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(operation)))
+		db.InstanceSet(tracingSpanKey, span)
+	}
+}
+
+func tracingAfter(db *gorm.DB) {
+	span, ok := db.InstanceGet(tracingSpanKey)
+	if !ok {
+		return
+	}
+	tracing.End(span.(trace.Span), db.Error,
+		semconv.DBSQLTableKey.String(db.Statement.Table),
+		semconv.DBStatementKey.String(db.Statement.SQL.String()))
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+
+	defaultTxRetries = 3
+	txRetryBackoff   = time.Millisecond * 50
+)
+
+// TxFunc is a function executed within transaction
+// ctx carries the transaction, so that Storage.DB(ctx) returns it
+type TxFunc func(ctx context.Context) error
+
+// AfterCommitFunc is executed after the outermost transaction is committed
+type AfterCommitFunc func(ctx context.Context)
+
+// txKey is a context key of a transaction. It's bound to Storage, so that transactions of different storages don't interfere
+type txKey struct {
+	s *Storage
+}
+
+// txState holds transaction of the current scope
+type txState struct {
+	tx          *gorm.DB
+	level       int
+	afterCommit []AfterCommitFunc
+}
+
+// DB returns transaction if ctx carries one, otherwise returns storage instance
+// the returned instance is bound to ctx
+func (s *Storage) DB(ctx context.Context) *gorm.DB {
+	if st := s.txState(ctx); st != nil {
+		return st.tx.WithContext(ctx)
+	}
+	return s.Instance.WithContext(ctx)
+}
+
+// InTx checks if ctx carries transaction of the storage
+func (s *Storage) InTx(ctx context.Context) bool {
+	return s.txState(ctx) != nil
+}
+
+// WithTx executes fn within transaction
+// if ctx already carries transaction, a nested transaction based on savepoint is started
+// the outermost transaction is retried on serialization failures and deadlocks, so fn must be safe to re-execute
+func (s *Storage) WithTx(ctx context.Context, fn TxFunc) error {
+	if parent := s.txState(ctx); parent != nil {
+		return s.nestedTx(ctx, parent, fn)
+	}
+
+	retries := s.txRetries
+	if retries <= 0 {
+		retries = defaultTxRetries
+	}
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			s.logger().Pr("db").Cmp("tx").Mth("with-tx").C(ctx).E(err).WarnF("retrying transaction, attempt %d", attempt)
+			select {
+			case <-time.After(txRetryBackoff * time.Duration(attempt)):
+			case <-ctx.Done():
+				return ErrTxRetry(ctx.Err())
+			}
+		}
+		var hooks []AfterCommitFunc
+		hooks, err = s.tx(ctx, fn)
+		if err == nil {
+			for _, h := range hooks {
+				h(ctx)
+			}
+			return nil
+		}
+		if !IsRetryable(err) {
+			return err
+		}
+	}
+	return ErrTxRetry(err)
+}
+
+// AfterCommit registers hook executed after the outermost transaction is committed
+// hooks registered within a nested transaction which is rolled back are discarded
+// if ctx carries no transaction, hook is executed immediately
+func (s *Storage) AfterCommit(ctx context.Context, fn AfterCommitFunc) {
+	if st := s.txState(ctx); st != nil {
+		st.afterCommit = append(st.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+func (s *Storage) txState(ctx context.Context) *txState {
+	if ctx == nil {
+		return nil
+	}
+	st, _ := ctx.Value(txKey{s: s}).(*txState)
+	return st
+}
+
+func (s *Storage) tx(ctx context.Context, fn TxFunc) (hooks []AfterCommitFunc, err error) {
+
+	tx := s.Instance.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return nil, ErrTxBegin(tx.Error)
+	}
+
+	st := &txState{tx: tx}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{s: s}, st)); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		// serialization failures are returned as is, so that caller is able to retry
+		if IsRetryable(err) {
+			return nil, err
+		}
+		return nil, ErrTxCommit(err)
+	}
+	committed = true
+
+	return st.afterCommit, nil
+}
+
+func (s *Storage) nestedTx(ctx context.Context, parent *txState, fn TxFunc) error {
+
+	st := &txState{tx: parent.tx, level: parent.level + 1}
+	savePoint := fmt.Sprintf("sp%d", st.level)
+
+	if err := parent.tx.SavePoint(savePoint).Error; err != nil {
+		return ErrTxSavePoint(err)
+	}
+
+	released := false
+	defer func() {
+		if !released {
+			parent.tx.RollbackTo(savePoint)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{s: s}, st)); err != nil {
+		return err
+	}
+	if err := parent.tx.Exec("RELEASE SAVEPOINT " + savePoint).Error; err != nil {
+		return ErrTxSavePoint(err)
+	}
+	released = true
+
+	// hooks are propagated to parent, so they are executed only if the outermost transaction is committed
+	parent.afterCommit = append(parent.afterCommit, st.afterCommit...)
+
+	return nil
+}
+
+// IsRetryable checks if error is caused by serialization failure or deadlock, so that transaction can be retried
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgSerializationFailure || pgErr.Code == pgDeadlockDetected
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	kitLog "git.jetbrains.space/orbi/fcsd/kit/log"
+	"github.com/jackc/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// txRecorder is a fake sql driver recording statements, commit fails with commitErrs in turn
+type txRecorder struct {
+	sync.Mutex
+	stmts      []string
+	commitErrs []error
+}
+
+func (r *txRecorder) add(stmt string) {
+	r.Lock()
+	defer r.Unlock()
+	r.stmts = append(r.stmts, stmt)
+}
+
+func (r *txRecorder) log() string {
+	r.Lock()
+	defer r.Unlock()
+	return strings.Join(r.stmts, ";")
+}
+
+func (r *txRecorder) Connect(context.Context) (driver.Conn, error) { return &txConn{r: r}, nil }
+func (r *txRecorder) Driver() driver.Driver                        { return nil }
+
+type txConn struct {
+	r *txRecorder
+}
+
+func (c *txConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *txConn) Close() error                        { return nil }
+func (c *txConn) Begin() (driver.Tx, error) {
+	c.r.add("BEGIN")
+	return c, nil
+}
+func (c *txConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.r.add(query)
+	return driver.RowsAffected(1), nil
+}
+func (c *txConn) Commit() error {
+	c.r.Lock()
+	defer c.r.Unlock()
+	if len(c.r.commitErrs) > 0 {
+		err := c.r.commitErrs[0]
+		c.r.commitErrs = c.r.commitErrs[1:]
+		c.r.stmts = append(c.r.stmts, "COMMIT FAILED")
+		return err
+	}
+	c.r.stmts = append(c.r.stmts, "COMMIT")
+	return nil
+}
+func (c *txConn) Rollback() error {
+	c.r.add("ROLLBACK")
+	return nil
+}
+
+func txStorage(t *testing.T, commitErrs ...error) (*Storage, *txRecorder) {
+	r := &txRecorder{commitErrs: commitErrs}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(r)}), &gorm.Config{DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := kitLog.Init(&kitLog.Config{Level: kitLog.ErrorLevel})
+	return &Storage{Instance: db, logger: func() kitLog.CLogger { return kitLog.L(logger) }, txRetries: 2}, r
+}
+
+func Test_NestedTx(t *testing.T) {
+	s, r := txStorage(t)
+	ctx := context.Background()
+
+	var hooks []string
+	hook := func(name string) AfterCommitFunc {
+		return func(context.Context) { hooks = append(hooks, name) }
+	}
+
+	err := s.WithTx(ctx, func(ctx context.Context) error {
+		s.AfterCommit(ctx, hook("outer"))
+		s.DB(ctx).Exec("UPDATE a")
+		// nested transaction is rolled back to its savepoint, its hooks are discarded
+		_ = s.WithTx(ctx, func(ctx context.Context) error {
+			s.AfterCommit(ctx, hook("rolled back"))
+			s.DB(ctx).Exec("UPDATE b")
+			return errors.New("failed")
+		})
+		return s.WithTx(ctx, func(ctx context.Context) error {
+			s.AfterCommit(ctx, hook("nested"))
+			return s.WithTx(ctx, func(ctx context.Context) error {
+				s.DB(ctx).Exec("UPDATE c")
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "BEGIN;UPDATE a;SAVEPOINT sp1;UPDATE b;ROLLBACK TO SAVEPOINT sp1;SAVEPOINT sp1;SAVEPOINT sp2;UPDATE c;RELEASE SAVEPOINT sp2;RELEASE SAVEPOINT sp1;COMMIT"
+	if r.log() != expected {
+		t.Fatalf("unexpected statements %s", r.log())
+	}
+	if strings.Join(hooks, ",") != "outer,nested" {
+		t.Fatalf("unexpected hooks %v", hooks)
+	}
+}
+
+func Test_TxRetry(t *testing.T) {
+	serialization := &pgconn.PgError{Code: pgSerializationFailure}
+
+	s, r := txStorage(t, serialization)
+	attempts, hooks := 0, 0
+	err := s.WithTx(context.Background(), func(ctx context.Context) error {
+		attempts++
+		s.AfterCommit(ctx, func(context.Context) { hooks++ })
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || hooks != 1 {
+		t.Fatalf("unexpected attempts %d, hooks %d", attempts, hooks)
+	}
+	if r.log() != "BEGIN;COMMIT FAILED;BEGIN;COMMIT" {
+		t.Fatalf("unexpected statements %s", r.log())
+	}
+
+	// retries are exceeded
+	s, _ = txStorage(t, serialization, serialization, serialization)
+	hooks = 0
+	err = s.WithTx(context.Background(), func(ctx context.Context) error {
+		s.AfterCommit(ctx, func(context.Context) { hooks++ })
+		return nil
+	})
+	assertCode(t, err, ErrCodeTxRetry)
+	if hooks != 0 {
+		t.Fatal("hooks mustn't be executed if transaction isn't committed")
+	}
+
+	// other errors aren't retried
+	s, r = txStorage(t)
+	attempts = 0
+	err = s.WithTx(context.Background(), func(ctx context.Context) error {
+		attempts++
+		s.AfterCommit(ctx, func(context.Context) { hooks++ })
+		return errors.New("failed")
+	})
+	if err == nil || attempts != 1 || hooks != 0 {
+		t.Fatalf("unexpected attempts %d, hooks %d, error %v", attempts, hooks, err)
+	}
+	if r.log() != "BEGIN;ROLLBACK" {
+		t.Fatalf("unexpected statements %s", r.log())
+	}
+}
+
+func assertCode(t *testing.T, err error, code string) {
+	t.Helper()
+	if appErr, ok := er.Is(err); !ok || appErr.Code() != code {
+		t.Fatalf("%s expected, got %v", code, err)
+	}
+}
@@ -0,0 +1,210 @@
+package er
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+)
+
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// CodeDef describes an error code registered in the catalog
+type CodeDef struct {
+	// Code - unique error code, e.g. DB-003
+	Code string `json:"code"`
+	// Message - default (english) message template in fmt format
+	Message string `json:"message"`
+	// HttpStatus - HTTP status used if error doesn't have one attached, 0 if not specified
+	HttpStatus uint32 `json:"httpStatus,omitempty"`
+	// GrpcStatus - gRPC status used if error doesn't have one attached, nil if not specified
+	GrpcStatus *uint32 `json:"grpcStatus,omitempty"`
+	// Severity - error severity
+	Severity string `json:"severity,omitempty"`
+	// Translations - message templates by locale, populated by AddTranslations
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+// catalog is a registry of error codes
+type catalog struct {
+	sync.RWMutex
+	defs map[string]*CodeDef
+	uni  *ut.UniversalTranslator
+}
+
+var defaultCatalog = &catalog{
+	defs: make(map[string]*CodeDef),
+	uni:  ut.New(en.New(), en.New()),
+}
+
+// GrpcCode is a helper to populate CodeDef.GrpcStatus
+func GrpcCode(status uint32) *uint32 {
+	return &status
+}
+
+// Register registers codes in the catalog
+// it's supposed to be called from package init, so registering the same code twice panics
+func Register(defs ...*CodeDef) {
+	defaultCatalog.Lock()
+	defer defaultCatalog.Unlock()
+	for _, d := range defs {
+		if _, ok := defaultCatalog.defs[d.Code]; ok {
+			panic(fmt.Sprintf("error code %s is already registered", d.Code))
+		}
+		if d.Severity == "" {
+			d.Severity = SeverityError
+		}
+		defaultCatalog.defs[d.Code] = d
+	}
+}
+
+// copy returns a deep copy of the definition, so that it can be read while translations are added
+func (d *CodeDef) copy() *CodeDef {
+	c := *d
+	if d.GrpcStatus != nil {
+		c.GrpcStatus = GrpcCode(*d.GrpcStatus)
+	}
+	if d.Translations != nil {
+		c.Translations = make(map[string]string, len(d.Translations))
+		for l, msg := range d.Translations {
+			c.Translations[l] = msg
+		}
+	}
+	return &c
+}
+
+// Lookup returns a copy of code definition
+func Lookup(code string) (*CodeDef, bool) {
+	defaultCatalog.RLock()
+	defer defaultCatalog.RUnlock()
+	d, ok := defaultCatalog.defs[code]
+	if !ok {
+		return nil, false
+	}
+	return d.copy(), true
+}
+
+// lookup returns registered definition itself, only fields not changed after registration may be read (not Translations)
+func lookup(code string) (*CodeDef, bool) {
+	defaultCatalog.RLock()
+	defer defaultCatalog.RUnlock()
+	d, ok := defaultCatalog.defs[code]
+	return d, ok
+}
+
+// Codes returns copies of all registered codes sorted by code
+func Codes() []*CodeDef {
+	defaultCatalog.RLock()
+	defer defaultCatalog.RUnlock()
+	res := make([]*CodeDef, 0, len(defaultCatalog.defs))
+	for _, d := range defaultCatalog.defs {
+		res = append(res, d.copy())
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Code < res[j].Code })
+	return res
+}
+
+// RegisterLocale adds a locale translations can be provided for
+// e.g. er.RegisterLocale(ru.New()) with github.com/go-playground/locales/ru
+func RegisterLocale(l locales.Translator) error {
+	defaultCatalog.Lock()
+	defer defaultCatalog.Unlock()
+	return defaultCatalog.uni.AddTranslator(l, true)
+}
+
+// AddTranslations adds message templates for the locale keyed by code
+// templates must contain the same fmt verbs in the same order as the error message they translate
+func AddTranslations(locale string, messages map[string]string) error {
+	defaultCatalog.Lock()
+	defer defaultCatalog.Unlock()
+
+	trans, ok := defaultCatalog.uni.GetTranslator(locale)
+	if !ok {
+		return fmt.Errorf("locale %s isn't registered", locale)
+	}
+	for code, msg := range messages {
+		if err := trans.Add(code, msg, true); err != nil {
+			return err
+		}
+		if d, ok := defaultCatalog.defs[code]; ok {
+			if d.Translations == nil {
+				d.Translations = make(map[string]string)
+			}
+			d.Translations[trans.Locale()] = msg
+		}
+	}
+	return nil
+}
+
+// Translate returns message of the error translated to the first matching locale
+// locales are in order of preference, e.g. parsed from Accept-Language. Region specific locales (ru-RU) fallback to language (ru)
+// if there is no translation, error message is returned
+func Translate(err error, locales ...string) string {
+	appErr, ok := Is(err)
+	if !ok {
+		return err.Error()
+	}
+
+	defaultCatalog.RLock()
+	defer defaultCatalog.RUnlock()
+
+	for _, l := range locales {
+		// go-playground locales use underscore as separator (ru_RU)
+		trans, found := defaultCatalog.uni.GetTranslator(strings.ReplaceAll(l, "-", "_"))
+		if !found {
+			if i := strings.IndexAny(l, "-_"); i > 0 {
+				trans, found = defaultCatalog.uni.GetTranslator(l[:i])
+			}
+		}
+		if !found {
+			continue
+		}
+		if tmpl, err := trans.T(appErr.Code()); err == nil {
+			return fmt.Sprintf(tmpl, appErr.args...)
+		}
+	}
+
+	return appErr.Message()
+}
+
+// ExportJson writes the catalog as JSON array
+func ExportJson(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Codes())
+}
+
+// ExportMarkdown writes the catalog as Markdown table
+func ExportMarkdown(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "| Code | HTTP | gRPC | Severity | Message |"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "|------|------|------|----------|---------|"); err != nil {
+		return err
+	}
+	for _, d := range Codes() {
+		httpSt, grpcSt := "", ""
+		if d.HttpStatus != 0 {
+			httpSt = fmt.Sprint(d.HttpStatus)
+		}
+		if d.GrpcStatus != nil {
+			grpcSt = fmt.Sprint(*d.GrpcStatus)
+		}
+		msg := strings.ReplaceAll(d.Message, "|", `\|`)
+		if _, err := fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", d.Code, httpSt, grpcSt, d.Severity, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
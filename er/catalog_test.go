@@ -0,0 +1,129 @@
+package er
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/locales/ru"
+)
+
+func init() {
+	Register(
+		&CodeDef{Code: "TST-901", Message: "item %s not found", HttpStatus: 404, GrpcStatus: GrpcCode(5)},
+		&CodeDef{Code: "TST-902", Message: "validation failed", HttpStatus: 400},
+	)
+}
+
+func Test_Register(t *testing.T) {
+	d, ok := Lookup("TST-901")
+	if !ok || d.Message != "item %s not found" || d.Severity != SeverityError {
+		t.Fatalf("unexpected code definition %+v", d)
+	}
+	if _, ok := Lookup("TST-999"); ok {
+		t.Fatal("unregistered code found")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering the same code twice must panic")
+		}
+	}()
+	Register(&CodeDef{Code: "TST-901", Message: "duplicate"})
+}
+
+func Test_WithCode(t *testing.T) {
+	cause := errors.New("cause")
+	for _, err := range []error{
+		WithCode("TST-901", "abc").Err(),
+		WrapWithCode(cause, "TST-901", "abc").Err(),
+	} {
+		appErr, _ := Is(err)
+		if !strings.HasPrefix(appErr.Message(), "item abc not found") {
+			t.Fatalf("unexpected message %s", appErr.Message())
+		}
+		if st := appErr.HttpStatus(); st == nil || *st != 404 {
+			t.Fatalf("unexpected http status %v", st)
+		}
+		if st := appErr.GrpcStatus(); st == nil || *st != 5 {
+			t.Fatalf("unexpected grpc status %v", st)
+		}
+	}
+	if !errors.Is(WrapWithCode(cause, "TST-901", "abc").Err(), cause) {
+		t.Fatal("cause isn't found in chain")
+	}
+
+	appErr, _ := Is(AggregateWithCode([]error{New("TST-001", "first")}, "TST-902").Err())
+	if appErr.Message() != "validation failed" || len(appErr.Errors()) != 1 || *appErr.HttpStatus() != 400 {
+		t.Fatalf("unexpected aggregate %v", appErr)
+	}
+
+	// unregistered code is built from args
+	appErr, _ = Is(WithCode("TST-999", "a", 1).Err())
+	if appErr.Message() != "a 1" || appErr.HttpStatus() != nil {
+		t.Fatalf("unexpected error %v", appErr)
+	}
+}
+
+func Test_Translate(t *testing.T) {
+	if err := RegisterLocale(ru.New()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddTranslations("ru", map[string]string{"TST-901": "элемент %s не найден"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddTranslations("de", map[string]string{"TST-901": "nicht gefunden"}); err == nil {
+		t.Fatal("unregistered locale must fail")
+	}
+	if d, _ := Lookup("TST-901"); d.Translations["ru"] != "элемент %s не найден" {
+		t.Fatalf("translation isn't added to catalog %v", d.Translations)
+	}
+
+	err := WithCode("TST-901", "abc").Err()
+	tests := []struct {
+		locales []string
+		msg     string
+	}{
+		{[]string{"ru"}, "элемент abc не найден"},
+		{[]string{"ru-RU"}, "элемент abc не найден"},
+		{[]string{"de", "ru"}, "элемент abc не найден"},
+		{[]string{"de"}, "item abc not found"},
+		{nil, "item abc not found"},
+	}
+	for _, tt := range tests {
+		if msg := Translate(err, tt.locales...); msg != tt.msg {
+			t.Fatalf("%v: unexpected message %s", tt.locales, msg)
+		}
+	}
+	if msg := Translate(errors.New("plain"), "ru"); msg != "plain" {
+		t.Fatalf("unexpected message %s", msg)
+	}
+}
+
+func Test_CatalogConcurrentRead(t *testing.T) {
+	if err := RegisterLocale(ru.New()); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = AddTranslations("ru", map[string]string{"TST-902": fmt.Sprintf("ошибка %d", i)})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := ExportJson(ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	// changing returned definition doesn't affect the catalog
+	d, _ := Lookup("TST-902")
+	d.Translations["ru"] = "changed"
+	if d, _ := Lookup("TST-902"); d.Translations["ru"] != "ошибка 99" {
+		t.Fatalf("unexpected translation %s", d.Translations["ru"])
+	}
+}
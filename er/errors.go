@@ -2,15 +2,23 @@ package er
 
 import (
 	"context"
+	stdErrors "errors"
 	"fmt"
 	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
 	"github.com/pkg/errors"
 	"reflect"
+	"strings"
 )
 
 // FF specifies list of fields
 type FF map[string]interface{}
 
+// well-known fields interpreted by transport layers
+const (
+	FieldKeyField      = "field"      // FieldKeyField - request field the error relates to (e.g. validation failure)
+	FieldKeyRetryAfter = "retryAfter" // FieldKeyRetryAfter - time.Duration after which request can be retried
+)
+
 // AppError specifies application error object
 type AppError struct {
 	error
@@ -18,6 +26,18 @@ type AppError struct {
 	httpStatus *uint32
 	code       string
 	fields     FF
+	// args of message format, kept to be able to translate message
+	args []interface{}
+	// errs - aggregated errors
+	errs []error
+}
+
+// Code is a sentinel allowing matching AppError by code with errors.Is
+// e.g. errors.Is(err, er.Code("DB-003"))
+type Code string
+
+func (c Code) Error() string {
+	return string(c)
 }
 
 // AppErrBuilder allows building AppError object
@@ -33,6 +53,8 @@ type AppErrBuilder interface {
 	// HttpSt attaches HTTP status
 	// it gives some hint to API gateway layer what HTTP status to return client
 	HttpSt(status uint32) AppErrBuilder
+	// Cause attaches cause without changing error message, cause is available with errors.Unwrap
+	Cause(cause error) AppErrBuilder
 	// Err builds error with all specified attributes
 	Err() error
 }
@@ -79,6 +101,13 @@ func (b *appErrBuildImpl) HttpSt(status uint32) AppErrBuilder {
 	return b
 }
 
+func (b *appErrBuildImpl) Cause(cause error) AppErrBuilder {
+	if cause != nil {
+		b.appErr.error = &causedErr{error: b.appErr.error, cause: cause}
+	}
+	return b
+}
+
 func (b *appErrBuildImpl) Err() error {
 	return b.appErr
 }
@@ -91,6 +120,57 @@ func WithBuilder(code string, format string, args ...interface{}) AppErrBuilder
 	return b
 }
 
+// WithCode creates a new AppErrBuilder using message template and statuses registered for the code in the catalog
+// if code isn't registered, message is built from args only
+func WithCode(code string, args ...interface{}) AppErrBuilder {
+	d, ok := lookup(code)
+	if !ok {
+		return WithBuilder(code, strings.TrimSpace(strings.Repeat("%v ", len(args))), args...)
+	}
+	return withStatuses(WithBuilder(code, d.Message, args...), d)
+}
+
+// Aggregate creates a new AppErrBuilder of an error carrying multiple errors (e.g. validation failures)
+// nil errors are skipped
+func Aggregate(errs []error, code string, format string, args ...interface{}) AppErrBuilder {
+	b := WithBuilder(code, format, args...).(*appErrBuildImpl)
+	for _, e := range errs {
+		if e != nil {
+			b.appErr.errs = append(b.appErr.errs, e)
+		}
+	}
+	return b
+}
+
+// WrapWithCode wraps error and returns builder using message template and statuses registered for the code in the catalog
+// if code isn't registered, message is built from args only
+func WrapWithCode(cause error, code string, args ...interface{}) AppErrBuilder {
+	d, ok := lookup(code)
+	if !ok {
+		return WrapWithBuilder(cause, code, strings.TrimSpace(strings.Repeat("%v ", len(args))), args...)
+	}
+	return withStatuses(WrapWithBuilder(cause, code, d.Message, args...), d)
+}
+
+// AggregateWithCode creates a new AppErrBuilder of an aggregate error using the catalog (see WithCode)
+func AggregateWithCode(errs []error, code string, args ...interface{}) AppErrBuilder {
+	d, ok := lookup(code)
+	if !ok {
+		return Aggregate(errs, code, strings.TrimSpace(strings.Repeat("%v ", len(args))), args...)
+	}
+	return withStatuses(Aggregate(errs, code, d.Message, args...), d)
+}
+
+func withStatuses(b AppErrBuilder, d *CodeDef) AppErrBuilder {
+	if d.HttpStatus != 0 {
+		b.HttpSt(d.HttpStatus)
+	}
+	if d.GrpcStatus != nil {
+		b.GrpcSt(*d.GrpcStatus)
+	}
+	return b
+}
+
 // WrapWithBuilder wraps error and returns builder
 func WrapWithBuilder(cause error, code string, format string, args ...interface{}) AppErrBuilder {
 	b := &appErrBuildImpl{
@@ -105,6 +185,7 @@ func newAppErr(code string, format string, args ...interface{}) *AppError {
 		error:  errors.Errorf(format, args...),
 		code:   code,
 		fields: make(FF),
+		args:   args,
 	}
 }
 
@@ -147,11 +228,76 @@ func (e *AppError) Fields() FF {
 	return e.fields
 }
 
-// Fields returns fields
+// GrpcStatus returns gRPC status if attached, otherwise the one registered for the code in the catalog
 func (e *AppError) GrpcStatus() *uint32 {
+	if e.grpcStatus == nil {
+		if d, ok := lookup(e.code); ok {
+			return d.GrpcStatus
+		}
+	}
 	return e.grpcStatus
 }
 
+// HttpStatus returns HTTP status if attached, otherwise the one registered for the code in the catalog
+func (e *AppError) HttpStatus() *uint32 {
+	if e.httpStatus == nil {
+		if d, ok := lookup(e.code); ok && d.HttpStatus != 0 {
+			st := d.HttpStatus
+			return &st
+		}
+	}
+	return e.httpStatus
+}
+
+// Errors returns aggregated errors, nil if error isn't aggregate
+func (e *AppError) Errors() []error {
+	return e.errs
+}
+
+// Unwrap returns the cause of AppError
+func (e *AppError) Unwrap() error {
+	return e.error
+}
+
+// Is allows matching by code with errors.Is, both Code and *AppError targets are compared by code
+// aggregated errors are matched as well
+func (e *AppError) Is(target error) bool {
+	switch t := target.(type) {
+	case Code:
+		if e.code == string(t) {
+			return true
+		}
+	case *AppError:
+		if t != nil && e.code == t.code {
+			return true
+		}
+	}
+	for _, err := range e.errs {
+		if stdErrors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first aggregated error matching target
+func (e *AppError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if stdErrors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Severity returns severity registered for the code in the catalog, SeverityError if code isn't registered
+func (e *AppError) Severity() string {
+	if d, ok := lookup(e.code); ok {
+		return d.Severity
+	}
+	return SeverityError
+}
+
 // Wrap wraps error to a AppError object
 func Wrap(cause error, code string, format string, args ...interface{}) error {
 	return wrap(cause, code, format, args...)
@@ -162,16 +308,37 @@ func wrap(cause error, code string, format string, args ...interface{}) *AppErro
 		error:  errors.Wrapf(cause, format, args...),
 		code:   code,
 		fields: make(FF),
+		args:   args,
 	}
 }
 
-// Is checks if error interface is asserted to *AppError
-// if true, it returns *AppError
+// Is checks if there is *AppError in the error chain
+// if true, it returns the first *AppError found
 func Is(e error) (*AppError, bool) {
-	appErr, ok := e.(*AppError)
+	var appErr *AppError
+	ok := stdErrors.As(e, &appErr)
 	return appErr, ok
 }
 
+// causedErr keeps message of the original error and unwraps to the cause
+type causedErr struct {
+	error
+	cause error
+}
+
+func (c *causedErr) Unwrap() error {
+	return c.cause
+}
+
+// Format keeps stack trace of the original error
+func (c *causedErr) Format(s fmt.State, verb rune) {
+	if f, ok := c.error.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+	_, _ = fmt.Fprint(s, c.error.Error())
+}
+
 type withStackAppErr struct {
 	*AppError
 }
@@ -179,3 +346,7 @@ type withStackAppErr struct {
 func (s *withStackAppErr) Error() string {
 	return s.AppError.WithStack()
 }
+
+func (s *withStackAppErr) Unwrap() error {
+	return s.AppError
+}
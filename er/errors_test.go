@@ -0,0 +1,58 @@
+package er
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_IsWrapped(t *testing.T) {
+	err := fmt.Errorf("context: %w", New("TST-001", "test"))
+
+	appErr, ok := Is(err)
+	if !ok {
+		t.Fatal("app error isn't found in chain")
+	}
+	if appErr.Code() != "TST-001" {
+		t.Fatalf("unexpected code %s", appErr.Code())
+	}
+	if !errors.Is(err, Code("TST-001")) {
+		t.Fatal("errors.Is by code failed")
+	}
+	if errors.Is(err, Code("TST-002")) {
+		t.Fatal("errors.Is matched wrong code")
+	}
+}
+
+func Test_IsCause(t *testing.T) {
+	cause := errors.New("cause")
+
+	if !errors.Is(Wrap(cause, "TST-003", "wrapped"), cause) {
+		t.Fatal("cause isn't found in chain")
+	}
+	if !errors.Is(Wrap(New("TST-001", "inner"), "TST-002", "outer"), Code("TST-001")) {
+		t.Fatal("wrapped app error isn't matched by code")
+	}
+}
+
+func Test_Aggregate(t *testing.T) {
+	err := Aggregate([]error{
+		New("TST-001", "first"),
+		nil,
+		WithBuilder("TST-002", "second").HttpSt(400).Err(),
+	}, "TST-100", "validation failed").Err()
+
+	appErr, ok := Is(err)
+	if !ok {
+		t.Fatal("aggregate isn't app error")
+	}
+	if len(appErr.Errors()) != 2 {
+		t.Fatalf("unexpected number of errors %d", len(appErr.Errors()))
+	}
+	if !errors.Is(err, Code("TST-100")) || !errors.Is(err, Code("TST-002")) {
+		t.Fatal("aggregated codes aren't matched")
+	}
+	if errors.Is(err, Code("TST-003")) {
+		t.Fatal("unexpected code matched")
+	}
+}
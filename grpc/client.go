@@ -2,32 +2,103 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"time"
+
 	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
-	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"git.jetbrains.space/orbi/fcsd/kit/utils"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 )
 
+const (
+	LbRoundRobin = "round_robin" // LbRoundRobin - balances calls across all resolved addresses
+	LbPickFirst  = "pick_first"  // LbPickFirst - gRPC default, uses the first resolved address
+)
+
 // ClientConfig is gRPC client configuration
 type ClientConfig struct {
 	Host string
 	Port string
+	// Tls - if true, connection is secured with TLS
+	Tls bool `config:"tls"`
+	// TlsCaFile - CA certificates used to verify server, system pool is used if empty
+	TlsCaFile string `config:"tls-ca-file"`
+	// TlsCertFile, TlsKeyFile - client certificate and key for mTLS
+	TlsCertFile string `config:"tls-cert-file"`
+	TlsKeyFile  string `config:"tls-key-file"`
+	// TlsServerName - overrides server name used to verify certificate
+	TlsServerName string `config:"tls-server-name"`
+	// Keepalive - keepalive pings, disabled if empty
+	Keepalive *ClientKeepaliveConfig `config:"keepalive"`
+	// Timeout - default timeout of unary calls, applied if context has no deadline
+	Timeout time.Duration `config:"timeout"`
+	// Retry - retry policy applied to all methods, no retries if empty
+	Retry *RetryConfig `config:"retry"`
+	// LoadBalancing - load balancing policy (round_robin, pick_first), if specified host is resolved via DNS
+	LoadBalancing string `config:"load-balancing"`
+	// Compression - compressor used for all calls (only gzip is supported)
+	Compression string `config:"compression"`
+	// MaxRecvMsgSize, MaxSendMsgSize - max message sizes in bytes, gRPC defaults if empty
+	MaxRecvMsgSize int `config:"max-recv-msg-size"`
+	MaxSendMsgSize int `config:"max-send-msg-size"`
+	// Metrics - if true, calls are counted and timed by method and code
+	Metrics bool `config:"metrics"`
+	// Tracing - if true, a span is created per call and W3C trace context is propagated in metadata (see tracing.Init)
+	Tracing bool `config:"tracing"`
+	// UnaryInterceptors, StreamInterceptors - user interceptors called after kit's ones
+	UnaryInterceptors  []grpc.UnaryClientInterceptor  `config:"-"`
+	StreamInterceptors []grpc.StreamClientInterceptor `config:"-"`
+}
+
+// ClientKeepaliveConfig configures client keepalive pings
+type ClientKeepaliveConfig struct {
+	// Time - ping is sent after this period of inactivity
+	Time time.Duration `config:"time"`
+	// Timeout - connection is closed if ping isn't acknowledged within timeout
+	Timeout time.Duration `config:"timeout"`
+	// PermitWithoutStream - if true, pings are sent even if there are no active calls
+	PermitWithoutStream bool `config:"permit-without-stream"`
+}
+
+// RetryConfig specifies retry policy
+// note, retries are disabled in grpc-go 1.40 used by kit unless GRPC_GO_RETRY=on env variable is set, otherwise the policy is ignored
+type RetryConfig struct {
+	// MaxAttempts - max number of attempts including the original one
+	MaxAttempts int `config:"max-attempts"`
+	// InitialBackoff, MaxBackoff, BackoffMultiplier - exponential backoff between attempts
+	InitialBackoff    time.Duration `config:"initial-backoff"`
+	MaxBackoff        time.Duration `config:"max-backoff"`
+	BackoffMultiplier float64       `config:"backoff-multiplier"`
+	// RetryableCodes - gRPC codes to retry on (e.g. UNAVAILABLE), UNAVAILABLE if empty
+	RetryableCodes []string `config:"retryable-codes"`
 }
 
 type Client struct {
 	*readinessAwaiter
 	Conn *grpc.ClientConn
+	cfg  *ClientConfig
 }
 
 func NewClient(cfg *ClientConfig) (*Client, error) {
 
-	c := &Client{}
+	c := &Client{cfg: cfg}
 
-	gc, err := grpc.Dial(fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
-		grpc.WithInsecure(),
-		grpc.WithChainUnaryInterceptor(grpc_middleware.ChainUnaryClient(c.unaryClientInterceptor())))
-	grpc.WithChainStreamInterceptor(grpc_middleware.ChainStreamClient(c.streamClientInterceptor()))
+	opts, err := c.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	target := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
+	if cfg.LoadBalancing != "" {
+		target = "dns:///" + target
+	}
+
+	gc, err := grpc.Dial(target, opts...)
 	if err != nil {
 		return nil, ErrGrpcClientDial(err)
 	}
@@ -38,13 +109,144 @@ func NewClient(cfg *ClientConfig) (*Client, error) {
 	return c, nil
 }
 
+func (c *Client) dialOptions() ([]grpc.DialOption, error) {
+
+	cfg := c.cfg
+
+	unary := []grpc.UnaryClientInterceptor{c.unaryClientInterceptor()}
+	stream := []grpc.StreamClientInterceptor{c.streamClientInterceptor()}
+	if cfg.Metrics {
+		unary = append(unary, unaryClientMetricsInterceptor())
+		stream = append(stream, streamClientMetricsInterceptor())
+	}
+	if cfg.Tracing {
+		unary = append(unary, unaryClientTracingInterceptor())
+		stream = append(stream, streamClientTracingInterceptor())
+	}
+	unary = append(unary, cfg.UnaryInterceptors...)
+	stream = append(stream, cfg.StreamInterceptors...)
+
+	opts := []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(stream...),
+	}
+
+	if cfg.Tls {
+		tlsCfg, err := utils.LoadTlsConfig(cfg.TlsCaFile, cfg.TlsCertFile, cfg.TlsKeyFile)
+		if err != nil {
+			return nil, ErrGrpcClientTls(err)
+		}
+		tlsCfg.ServerName = cfg.TlsServerName
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	if cfg.Keepalive != nil {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.Keepalive.Time,
+			Timeout:             cfg.Keepalive.Timeout,
+			PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
+		}))
+	}
+
+	if sc := c.serviceConfig(); sc != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(sc))
+	}
+
+	var callOpts []grpc.CallOption
+	if cfg.Compression != "" {
+		if cfg.Compression != gzip.Name {
+			return nil, ErrGrpcClientCompression(cfg.Compression)
+		}
+		callOpts = append(callOpts, grpc.UseCompressor(cfg.Compression))
+	}
+	if cfg.MaxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+
+	return opts, nil
+}
+
+// serviceConfig builds JSON service config with load balancing and retry policy
+func (c *Client) serviceConfig() string {
+
+	sc := map[string]interface{}{}
+
+	if c.cfg.LoadBalancing != "" {
+		sc["loadBalancingConfig"] = []map[string]interface{}{{c.cfg.LoadBalancing: map[string]interface{}{}}}
+	}
+
+	if r := c.cfg.Retry; r != nil && r.MaxAttempts > 1 {
+		initialBackoff, maxBackoff, multiplier, retryableCodes := r.InitialBackoff, r.MaxBackoff, r.BackoffMultiplier, r.RetryableCodes
+		if initialBackoff <= 0 {
+			initialBackoff = 100 * time.Millisecond
+		}
+		if maxBackoff <= 0 {
+			maxBackoff = time.Second
+		}
+		if multiplier <= 0 {
+			multiplier = 2
+		}
+		if len(retryableCodes) == 0 {
+			retryableCodes = []string{"UNAVAILABLE"}
+		}
+		sc["methodConfig"] = []map[string]interface{}{{
+			// empty name matches all methods
+			"name": []map[string]interface{}{{}},
+			"retryPolicy": map[string]interface{}{
+				"maxAttempts":          r.MaxAttempts,
+				"initialBackoff":       durationJson(initialBackoff),
+				"maxBackoff":           durationJson(maxBackoff),
+				"backoffMultiplier":    multiplier,
+				"retryableStatusCodes": retryableCodes,
+			},
+		}}
+	}
+
+	if len(sc) == 0 {
+		return ""
+	}
+	js, _ := json.Marshal(sc)
+	return string(js)
+}
+
+// durationJson formats duration as protobuf JSON duration (e.g. "0.1s")
+func durationJson(d time.Duration) string {
+	return fmt.Sprintf("%gs", d.Seconds())
+}
+
+// outgoingContext puts request context to outgoing metadata keeping metadata set by the caller
+func outgoingContext(ctx context.Context) context.Context {
+	md, ok := kitContext.FromContextToGrpcMD(ctx)
+	if !ok {
+		return ctx
+	}
+	outMd, _ := metadata.FromOutgoingContext(ctx)
+	outMd = outMd.Copy()
+	for k, v := range md {
+		outMd[k] = v
+	}
+	return metadata.NewOutgoingContext(ctx, outMd)
+}
+
 // this middleware is applied on client side
 // it retrieves session params from the context (normally it's populated in HTTP middleware or by another caller) and puts it to gRPS metadata
+// if context has no deadline, default timeout is applied
 func (c *Client) unaryClientInterceptor() grpc.UnaryClientInterceptor {
 	return func(parentCtx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		ctx := context.Background()
-		if md, ok := kitContext.FromContextToGrpcMD(parentCtx); ok {
-			ctx = metadata.NewOutgoingContext(ctx, md)
+		ctx := parentCtx
+		ctx = outgoingContext(ctx)
+		if _, ok := ctx.Deadline(); !ok && c.cfg.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+			defer cancel()
 		}
 		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
 			return toAppError(err)
@@ -55,10 +257,8 @@ func (c *Client) unaryClientInterceptor() grpc.UnaryClientInterceptor {
 
 func (c *Client) streamClientInterceptor() grpc.StreamClientInterceptor {
 	return func(parentCtx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-		ctx := context.Background()
-		if md, ok := kitContext.FromContextToGrpcMD(parentCtx); ok {
-			ctx = metadata.NewOutgoingContext(ctx, md)
-		}
+		ctx := parentCtx
+		ctx = outgoingContext(ctx)
 		clStream, err := streamer(ctx, desc, cc, method, opts...)
 		if err != nil {
 			err = toAppError(err)
@@ -0,0 +1,57 @@
+package grpc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func Test_NewClientServiceConfig(t *testing.T) {
+	c, err := NewClient(&ClientConfig{
+		Host:          "localhost",
+		Port:          "50051",
+		Timeout:       time.Second,
+		LoadBalancing: LbRoundRobin,
+		Compression:   "gzip",
+		Keepalive:     &ClientKeepaliveConfig{Time: time.Minute, Timeout: time.Second * 10},
+		Retry:         &RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond * 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Conn.Close() }()
+
+	var sc struct {
+		LoadBalancingConfig []map[string]interface{} `json:"loadBalancingConfig"`
+		MethodConfig        []struct {
+			RetryPolicy struct {
+				MaxAttempts          int      `json:"maxAttempts"`
+				InitialBackoff       string   `json:"initialBackoff"`
+				MaxBackoff           string   `json:"maxBackoff"`
+				RetryableStatusCodes []string `json:"retryableStatusCodes"`
+			} `json:"retryPolicy"`
+		} `json:"methodConfig"`
+	}
+	if err := json.Unmarshal([]byte(c.serviceConfig()), &sc); err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.LoadBalancingConfig) != 1 || sc.LoadBalancingConfig[0][LbRoundRobin] == nil {
+		t.Fatalf("round robin expected %v", sc.LoadBalancingConfig)
+	}
+	if len(sc.MethodConfig) != 1 {
+		t.Fatalf("method config expected %v", sc.MethodConfig)
+	}
+	rp := sc.MethodConfig[0].RetryPolicy
+	if rp.MaxAttempts != 3 || rp.InitialBackoff != "0.1s" || rp.MaxBackoff != "1s" {
+		t.Fatalf("unexpected retry policy %+v", rp)
+	}
+	if len(rp.RetryableStatusCodes) != 1 || rp.RetryableStatusCodes[0] != "UNAVAILABLE" {
+		t.Fatalf("default retryable codes expected %v", rp.RetryableStatusCodes)
+	}
+}
+
+func Test_NewClientCompressionNotSupported(t *testing.T) {
+	if _, err := NewClient(&ClientConfig{Host: "localhost", Port: "50051", Compression: "snappy"}); err == nil {
+		t.Fatal("error expected")
+	}
+}
@@ -1,21 +1,69 @@
 package grpc
 
-import "git.jetbrains.space/orbi/fcsd/kit/er"
+import (
+	"context"
+	"fmt"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"google.golang.org/grpc/codes"
+)
 
 const (
-	ErrCodeGrpcClientDial  = "GRPC-001"
-	ErrCodeGrpCInvoke      = "GRPC-002"
-	ErrCodeGrpcSrvListen   = "GRPC-003"
-	ErrCodeGrpcSrvServe    = "GRPC-004"
-	ErrCodeGrpcSrvNotReady = "GRPC-005"
+	ErrCodeGrpcClientDial     = "GRPC-001"
+	ErrCodeGrpCInvoke         = "GRPC-002"
+	ErrCodeGrpcSrvListen      = "GRPC-003"
+	ErrCodeGrpcSrvServe       = "GRPC-004"
+	ErrCodeGrpcSrvNotReady    = "GRPC-005"
+	ErrCodeGrpcRemote         = "GRPC-006"
+	ErrCodeGrpcClientTls      = "GRPC-007"
+	ErrCodeGrpcCompression    = "GRPC-008"
+	ErrCodeGrpcSrvTls         = "GRPC-009"
+	ErrCodeGrpcSrvPanic       = "GRPC-010"
+	ErrCodeGrpcTenantInvalid  = "GRPC-011"
+	ErrCodeGrpcTenantMismatch = "GRPC-012"
 )
 
 var (
-	ErrGrpcClientDial  = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeGrpcClientDial, "").Err() }
-	ErrGrpCInvoke      = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeGrpCInvoke, "").Err() }
-	ErrGrpcSrvListen   = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeGrpcSrvListen, "").Err() }
-	ErrGrpcSrvServe    = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeGrpcSrvServe, "").Err() }
+	ErrGrpcClientDial = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGrpcClientDial).Err() }
+	ErrGrpCInvoke     = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGrpCInvoke).Err() }
+	ErrGrpcSrvListen  = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGrpcSrvListen).Err() }
+	ErrGrpcSrvServe   = func(cause error) error { return er.WrapWithCode(cause, ErrCodeGrpcSrvServe).Err() }
+	ErrGrpcClientTls  = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeGrpcClientTls).Err()
+	}
+	ErrGrpcSrvTls = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeGrpcSrvTls).Err()
+	}
+	ErrGrpcSrvPanic = func(ctx context.Context, r interface{}) error {
+		return er.WithCode(ErrCodeGrpcSrvPanic, fmt.Sprint(r)).C(ctx).Err()
+	}
+	ErrGrpcTenantInvalid = func(ctx context.Context) error {
+		return er.WithCode(ErrCodeGrpcTenantInvalid).C(ctx).Err()
+	}
+	ErrGrpcTenantMismatch = func(ctx context.Context, tenantId string) error {
+		return er.WithCode(ErrCodeGrpcTenantMismatch).F(er.FF{"md-tenant": tenantId}).C(ctx).Err()
+	}
+	ErrGrpcClientCompression = func(compressor string) error {
+		return er.WithCode(ErrCodeGrpcCompression).F(er.FF{"compressor": compressor}).Err()
+	}
 	ErrGrpcSrvNotReady = func(svc string) error {
-		return er.WithBuilder(ErrCodeGrpcSrvNotReady, "service isn't ready within timeout").F(er.FF{"svc": svc}).Err()
+		return er.WithCode(ErrCodeGrpcSrvNotReady).F(er.FF{"svc": svc}).Err()
 	}
 )
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeGrpcClientDial, Message: "grpc client dial failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGrpCInvoke, Message: "grpc invoke failed"},
+		&er.CodeDef{Code: ErrCodeGrpcSrvListen, Message: "grpc server listen failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGrpcSrvServe, Message: "grpc server serve failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGrpcSrvNotReady, Message: "service isn't ready within timeout"},
+		&er.CodeDef{Code: ErrCodeGrpcRemote, Message: "remote service error"},
+		&er.CodeDef{Code: ErrCodeGrpcClientTls, Message: "load tls config", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGrpcCompression, Message: "compressor isn't supported", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGrpcSrvTls, Message: "load server tls", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGrpcSrvPanic, Message: "panic: %s", GrpcStatus: er.GrpcCode(uint32(codes.Internal)), Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeGrpcTenantInvalid, Message: "invalid tenant id", GrpcStatus: er.GrpcCode(uint32(codes.InvalidArgument)), Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeGrpcTenantMismatch, Message: "tenant id of metadata differs from request context", GrpcStatus: er.GrpcCode(uint32(codes.PermissionDenied)), Severity: er.SeverityWarning},
+	)
+}
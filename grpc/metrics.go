@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metrics interceptors are placed where errors are already converted to gRPC statuses
+
+func unaryServerMetricsInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observe(metrics.GrpcServerHandled, metrics.GrpcServerHandlingSeconds, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+func streamServerMetricsInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observe(metrics.GrpcServerHandled, metrics.GrpcServerHandlingSeconds, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func unaryClientMetricsInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		observe(metrics.GrpcClientHandled, metrics.GrpcClientHandlingSeconds, method, start, err)
+		return err
+	}
+}
+
+// streamClientMetricsInterceptor counts stream establishing only
+func streamClientMetricsInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		clStream, err := streamer(ctx, desc, cc, method, opts...)
+		observe(metrics.GrpcClientHandled, metrics.GrpcClientHandlingSeconds, method, start, err)
+		return clStream, err
+	}
+}
+
+func observe(handled *prometheus.CounterVec, seconds *prometheus.HistogramVec, method string, start time.Time, err error) {
+	handled.WithLabelValues(method, status.Code(err).String()).Inc()
+	seconds.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
@@ -6,11 +6,17 @@ import (
 	"fmt"
 	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
 	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"git.jetbrains.space/orbi/fcsd/kit/utils"
+	"git.jetbrains.space/orbi/fcsd/kit/validation"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"net"
+	"time"
 )
 
 // ServerConfig represents gRPC server configuration
@@ -18,6 +24,30 @@ type ServerConfig struct {
 	Host  string
 	Port  string
 	Trace bool
+	// DebugErrors - if true, debug info (stack) is added to error details and messages of internal errors are sent to clients
+	DebugErrors bool
+	// TlsCertFile, TlsKeyFile - server certificate and key, TLS is enabled if specified
+	TlsCertFile string
+	TlsKeyFile  string
+	// TlsCaFile - CA certificates used to verify client certificates
+	TlsCaFile string
+	// TlsClientAuth - client certificate verification: "" (none), "optional", "require"
+	TlsClientAuth string
+	// TlsReloadInterval - how often certificate files are checked for changes, 1 min by default
+	TlsReloadInterval time.Duration
+	// Validate - if true, requests implementing Validate() or having validation tags are validated
+	Validate bool
+	// Metrics - if true, calls are counted and timed by method and code
+	Metrics bool
+	// Tracing - if true, a span is created per call continuing W3C trace context from metadata (see tracing.Init)
+	Tracing bool
+	// Tenant - if true, tenant id is taken from x-tenant-id metadata, it must match tenant id of propagated request context
+	// tenant id is supplied by the client, so it's verified on authentication (see auth.Verifier)
+	// on public methods it's rejected unless auth.JwtConfig.PublicTenant accepts it, without auth interceptors it isn't verified at all
+	Tenant bool
+	// UnaryInterceptors, StreamInterceptors - user interceptors called after kit's ones (e.g. authentication)
+	UnaryInterceptors  []grpc.UnaryServerInterceptor  `config:"-"`
+	StreamInterceptors []grpc.StreamServerInterceptor `config:"-"`
 }
 
 type Server struct {
@@ -26,6 +56,7 @@ type Server struct {
 	Service string
 	logger  log.CLoggerFunc
 	config  *ServerConfig
+	tls     *utils.TlsReloader
 }
 
 func NewServer(service string, logger log.CLoggerFunc, config *ServerConfig) (*Server, error) {
@@ -37,7 +68,59 @@ func NewServer(service string, logger log.CLoggerFunc, config *ServerConfig) (*S
 		config:       config,
 	}
 
-	s.Srv = grpc.NewServer(grpc_middleware.WithUnaryServerChain(s.unaryServerInterceptor()), grpc_middleware.WithStreamServerChain(s.streamServerInterceptor()))
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	if config.Metrics {
+		unary = append(unary, unaryServerMetricsInterceptor())
+		stream = append(stream, streamServerMetricsInterceptor())
+	}
+	if config.Tracing {
+		unary = append(unary, unaryServerTracingInterceptor())
+		stream = append(stream, streamServerTracingInterceptor())
+	}
+	unary = append(unary, s.unaryServerInterceptor(), s.unaryRecoveryInterceptor())
+	if config.Tenant {
+		unary = append(unary, unaryServerTenantInterceptor())
+	}
+	unary = append(unary, config.UnaryInterceptors...)
+	if config.Validate {
+		unary = append(unary, validation.UnaryServerInterceptor())
+	}
+	stream = append(stream, s.streamServerInterceptor(), s.streamRecoveryInterceptor())
+	if config.Tenant {
+		stream = append(stream, streamServerTenantInterceptor())
+	}
+	stream = append(stream, config.StreamInterceptors...)
+
+	opts := []grpc.ServerOption{
+		grpc_middleware.WithUnaryServerChain(unary...),
+		grpc_middleware.WithStreamServerChain(stream...),
+	}
+
+	if config.TlsCertFile != "" {
+		tls, err := utils.NewTlsReloader(&utils.TlsServerOptions{
+			CertFile:       config.TlsCertFile,
+			KeyFile:        config.TlsKeyFile,
+			CaFile:         config.TlsCaFile,
+			ClientAuth:     config.TlsClientAuth,
+			ReloadInterval: config.TlsReloadInterval,
+			OnReload: func(err error) {
+				l := s.logger().Cmp(s.Service).Pr("grpc").Mth("tls-reload")
+				if err != nil {
+					l.E(ErrGrpcSrvTls(err)).Err()
+				} else {
+					l.Inf("certificates reloaded")
+				}
+			},
+		})
+		if err != nil {
+			return nil, ErrGrpcSrvTls(err)
+		}
+		s.tls = tls
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tls.Config())))
+	}
+
+	s.Srv = grpc.NewServer(opts...)
 
 	healthpb.RegisterHealthServer(s.Srv, s)
 
@@ -64,6 +147,28 @@ func (s *Server) Listen() error {
 
 func (s *Server) Close() {
 	s.Srv.Stop()
+	if s.tls != nil {
+		s.tls.Close()
+	}
+}
+
+// withPeerIdentity puts identity of the client verified by mTLS to the context
+func withPeerIdentity(ctx context.Context) context.Context {
+	if p, ok := peer.FromContext(ctx); ok {
+		if ti, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if id, ok := kitContext.PeerIdentityFromTls(&ti.State); ok {
+				return id.ToContext(ctx)
+			}
+		}
+	}
+	return ctx
+}
+
+func (s *Server) statusOptions() *statusOptions {
+	return &statusOptions{
+		domain: s.Service,
+		debug:  s.config.DebugErrors,
+	}
 }
 
 // this middleware is applied on server side
@@ -75,6 +180,7 @@ func (s *Server) unaryServerInterceptor() grpc.UnaryServerInterceptor {
 		if md, ok := metadata.FromIncomingContext(ctx); ok {
 			ctx = kitContext.FromGrpcMD(ctx, md)
 		}
+		ctx = withPeerIdentity(ctx)
 
 		resp, err := handler(ctx, req)
 
@@ -89,12 +195,13 @@ func (s *Server) unaryServerInterceptor() grpc.UnaryServerInterceptor {
 
 		// logging errors
 		if err != nil {
-			s.logger().Pr("grpc").Cmp(s.Service).Mth(info.FullMethod).E(err).St().Err()
+			s.logger().Pr("grpc").Cmp(s.Service).Mth(info.FullMethod).C(ctx).E(err).St().Err()
+			metrics.Error(err, metrics.SourceGrpc)
 		}
 
 		// convert to grpc status
 		if err != nil {
-			err = toGrpcStatus(err)
+			err = toGrpcStatus(err, s.statusOptions())
 		}
 
 		return resp, err
@@ -102,22 +209,94 @@ func (s *Server) unaryServerInterceptor() grpc.UnaryServerInterceptor {
 }
 
 // this middleware is applied on server side
-// it retrieves gRPC metadata and puts it to the context
+// it retrieves gRPC metadata and puts it to the context of the wrapped stream
 func (s *Server) streamServerInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 
-		err := handler(srv, ss)
+		ctx := ss.Context()
+
+		// convert metadata to request context
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			ctx = kitContext.FromGrpcMD(ctx, md)
+		}
+		ctx = withPeerIdentity(ctx)
+
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+
+		var stream grpc.ServerStream = wrapped
+		if s.config.Trace {
+			stream = &tracingServerStream{ServerStream: wrapped, s: s, method: info.FullMethod}
+		}
+
+		err := handler(srv, stream)
 
 		// logging errors
 		if err != nil {
-			s.logger().Pr("grpc").Cmp(s.Service).Mth(info.FullMethod).E(err).St().Err()
+			s.logger().Pr("grpc").Cmp(s.Service).Mth(info.FullMethod).C(ctx).E(err).St().Err()
+			metrics.Error(err, metrics.SourceGrpc)
 		}
 
 		// convert to grpc status
 		if err != nil {
-			err = toGrpcStatus(err)
+			err = toGrpcStatus(err, s.statusOptions())
 		}
 
 		return err
 	}
 }
+
+// tracingServerStream traces each received and sent message
+type tracingServerStream struct {
+	grpc.ServerStream
+	s      *Server
+	method string
+}
+
+func (t *tracingServerStream) trace(direction string, m interface{}) {
+	mJ, _ := json.Marshal(m)
+	t.s.logger().Pr("grpc").Cmp(t.s.Service).Mth(t.method).C(t.Context()).
+		F(log.FF{direction: string(mJ)}).
+		Trc()
+}
+
+func (t *tracingServerStream) RecvMsg(m interface{}) error {
+	err := t.ServerStream.RecvMsg(m)
+	if err == nil {
+		t.trace("rq", m)
+	}
+	return err
+}
+
+func (t *tracingServerStream) SendMsg(m interface{}) error {
+	err := t.ServerStream.SendMsg(m)
+	if err == nil {
+		t.trace("rs", m)
+	}
+	return err
+}
+
+// unaryRecoveryInterceptor converts panic in handler to error
+// it's applied after the context interceptor, so the error is logged with stack and converted to Internal status
+func (s *Server) unaryRecoveryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = ErrGrpcSrvPanic(ctx, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// streamRecoveryInterceptor converts panic in stream handler to error
+func (s *Server) streamRecoveryInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = ErrGrpcSrvPanic(ss.Context(), r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
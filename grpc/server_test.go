@@ -0,0 +1,114 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testHealthServer panics on Check and reports request context in Watch
+type testHealthServer struct {
+	healthpb.HealthServer
+}
+
+func (t *testHealthServer) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	var rs *healthpb.HealthCheckResponse
+	return &healthpb.HealthCheckResponse{Status: rs.Status}, nil
+}
+
+func (t *testHealthServer) Watch(rq *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	rCtx, ok := kitContext.Request(stream.Context())
+	if !ok || rCtx.Rid != "rid" {
+		return status.Error(codes.FailedPrecondition, "no request context")
+	}
+	return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
+
+func testServer(t *testing.T) healthpb.HealthClient {
+	logger := log.Init(&log.Config{Level: log.ErrorLevel})
+	s, err := NewServer("test", func() log.CLogger { return log.L(logger) }, &ServerConfig{Trace: true, Metrics: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.HealthServer = &testHealthServer{HealthServer: NewHealthServer()}
+
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = s.Srv.Serve(lis) }()
+	t.Cleanup(s.Close)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func Test_UnaryRecovery(t *testing.T) {
+	cl := testServer(t)
+
+	_, err := cl.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("internal status expected, got %v", err)
+	}
+	if appErr, ok := er.Is(toAppError(err)); !ok || appErr.Code() != ErrCodeGrpcSrvPanic {
+		t.Fatalf("panic error code expected, got %v", err)
+	}
+	if v := testutil.ToFloat64(metrics.GrpcServerHandled.WithLabelValues("/grpc.health.v1.Health/Check", codes.Internal.String())); v != 1 {
+		t.Fatalf("unexpected handled count %v", v)
+	}
+}
+
+func Test_StreamContext(t *testing.T) {
+	cl := testServer(t)
+
+	md, _ := kitContext.FromContextToGrpcMD(kitContext.NewRequestCtx().WithRequestId("rid").ToContext(context.Background()))
+	ctx := metadata.NewOutgoingContext(context.Background(), md)
+
+	stream, err := cl.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("unexpected status %v", rs.Status)
+	}
+}
+
+func Test_WithTenant(t *testing.T) {
+	incoming := func(rCtx *kitContext.RequestContext, tenantId string) context.Context {
+		md, _ := kitContext.FromContextToGrpcMD(rCtx.ToContext(context.Background()))
+		md.Set(MdTenantId, tenantId)
+		return kitContext.FromGrpcMD(metadata.NewIncomingContext(context.Background(), md), md)
+	}
+
+	ctx, err := withTenant(incoming(kitContext.NewRequestCtx().WithRequestId("rid"), "a"))
+	if tid, _ := kitContext.Tenant(ctx); err != nil || tid != "a" {
+		t.Fatalf("tenant expected, got %s %v", tid, err)
+	}
+
+	// request context of tenant "b" with metadata of tenant "a"
+	if _, err := withTenant(incoming(kitContext.NewRequestCtx().WithRequestId("rid").WithTenant("b"), "a")); err == nil {
+		t.Fatal("cross tenant call must be rejected")
+	}
+	if _, err := withTenant(incoming(kitContext.NewRequestCtx().WithRequestId("rid"), "a/b")); err == nil {
+		t.Fatal("invalid tenant must be rejected")
+	}
+}
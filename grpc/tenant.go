@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"context"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MdTenantId is a metadata key of tenant id set by clients not propagating request context (e.g. non-kit services)
+const MdTenantId = "x-tenant-id"
+
+func unaryServerTenantInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withTenant(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamServerTenantInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := withTenant(ss.Context())
+		if err != nil {
+			return err
+		}
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+// withTenant puts tenant id from metadata to the request context
+// tenant id propagated with request context takes precedence, but it must be the same if both are specified
+func withTenant(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	vals := md.Get(MdTenantId)
+	if len(vals) == 0 || vals[0] == "" {
+		return ctx, nil
+	}
+	tenantId := vals[0]
+	if !kitContext.IsValidTenantId(tenantId) {
+		return nil, ErrGrpcTenantInvalid(ctx)
+	}
+	rCtx, ok := kitContext.Request(ctx)
+	if !ok {
+		rCtx = kitContext.NewRequestCtx().WithNewRequestId()
+	}
+	if rCtx.GetTenantId() != "" && rCtx.GetTenantId() != tenantId {
+		return nil, ErrGrpcTenantMismatch(ctx, tenantId)
+	}
+	return rCtx.WithTenant(tenantId).ToContext(ctx), nil
+}
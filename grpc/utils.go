@@ -2,59 +2,328 @@ package grpc
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
 	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// keys of ErrorInfo metadata
+const (
+	errInfoHttpStatus = "httpStatus"
+	errInfoCauses     = "causes"
 )
 
-func toGrpcStatus(err error) error {
+// httpToGrpcStatus maps HTTP statuses to gRPC codes
+var httpToGrpcStatus = map[int]codes.Code{
+	http.StatusBadRequest:                   codes.InvalidArgument,
+	http.StatusUnauthorized:                 codes.Unauthenticated,
+	http.StatusForbidden:                    codes.PermissionDenied,
+	http.StatusNotFound:                     codes.NotFound,
+	http.StatusConflict:                     codes.Aborted,
+	http.StatusPreconditionFailed:           codes.FailedPrecondition,
+	http.StatusRequestedRangeNotSatisfiable: codes.OutOfRange,
+	http.StatusTooManyRequests:              codes.ResourceExhausted,
+	499:                                     codes.Canceled,
+	http.StatusInternalServerError:          codes.Internal,
+	http.StatusNotImplemented:               codes.Unimplemented,
+	http.StatusServiceUnavailable:           codes.Unavailable,
+	http.StatusGatewayTimeout:               codes.DeadlineExceeded,
+}
+
+// GrpcCodeFromHttp maps HTTP status to gRPC code
+func GrpcCodeFromHttp(httpStatus int) codes.Code {
+	if c, ok := httpToGrpcStatus[httpStatus]; ok {
+		return c
+	}
+	switch {
+	case httpStatus >= 400 && httpStatus < 500:
+		return codes.FailedPrecondition
+	case httpStatus >= 500:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// statusOptions specifies how errors are converted to gRPC status
+type statusOptions struct {
+	domain string // domain - ErrorInfo domain, normally service name
+	debug  bool   // debug - if true, DebugInfo with stack is attached
+}
+
+// toGrpcStatus converts error to gRPC status error
+func toGrpcStatus(err error, opts *statusOptions) error {
+	return appErrStatus(err, opts).Err()
+}
+
+// appErrCode returns gRPC code of AppError
+// attached gRPC status takes precedence, then it's mapped from HTTP status
+// aggregate error without status takes code of the first aggregated error
+func appErrCode(appErr *er.AppError) codes.Code {
+	if st := appErr.GrpcStatus(); st != nil {
+		return codes.Code(*st)
+	}
+	if st := appErr.HttpStatus(); st != nil {
+		return GrpcCodeFromHttp(int(*st))
+	}
+	if errs := appErr.Errors(); len(errs) > 0 {
+		if a, ok := er.Is(errs[0]); ok {
+			return appErrCode(a)
+		}
+	}
+	return codes.Unknown
+}
+
+// isInternal returns true if error is explicitly marked as internal: by gRPC status Internal or by 5xx HTTP status
+func isInternal(appErr *er.AppError) bool {
+	if st := appErr.GrpcStatus(); st != nil {
+		return codes.Code(*st) == codes.Internal
+	}
+	if st := appErr.HttpStatus(); st != nil {
+		return *st >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// appErrStatus builds gRPC status from error
+// besides AppErrorDetails, standard google.rpc details are attached: ErrorInfo, BadRequest, RetryInfo, DebugInfo (if debug enabled)
+// aggregated errors are put to details as nested statuses
+func appErrStatus(err error, opts *statusOptions) *status.Status {
 
-	// check if it's app error
-	if appErr, ok := er.Is(err); ok {
+	if opts == nil {
+		opts = &statusOptions{}
+	}
 
-		// is app error has grpc status populated, then set it up
-		var grpcStatus = codes.Unknown
-		if appErr.GrpcStatus() != nil {
-			c := *appErr.GrpcStatus()
-			grpcStatus = codes.Code(c)
+	appErr, ok := er.Is(err)
+	if !ok {
+		st := status.New(codes.Unknown, err.Error())
+		if !opts.debug {
+			return st
 		}
-		st := status.New(grpcStatus, appErr.Message())
+		return withDetails(st, &errdetails.DebugInfo{Detail: fmt.Sprintf("%+v", err)})
+	}
 
-		// marshal fields
-		ff, _ := json.Marshal(appErr.Fields())
+	code := appErrCode(appErr)
+	msg := appErr.Message()
+	// messages of internal errors (e.g. panics) may contain implementation details
+	if !opts.debug && isInternal(appErr) {
+		msg = codes.Internal.String()
+	}
+	st := status.New(code, msg)
+
+	// marshal fields
+	ff, _ := json.Marshal(appErr.Fields())
 
-		// put details to gRPC status
-		st, _ = st.WithDetails(&AppErrorDetails{
+	details := []proto.Message{
+		&AppErrorDetails{
 			Code:   appErr.Code(),
 			Fields: ff,
+		},
+		errorInfo(appErr, opts.domain),
+	}
+
+	if br := badRequest(appErr); br != nil {
+		details = append(details, br)
+	}
+
+	if d, ok := appErr.Fields()[er.FieldKeyRetryAfter].(time.Duration); ok {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(d)})
+	}
+
+	if opts.debug {
+		details = append(details, &errdetails.DebugInfo{
+			StackEntries: strings.Split(appErr.WithStack(), "\n"),
+			Detail:       err.Error(),
 		})
+	}
+
+	for _, e := range appErr.Errors() {
+		details = append(details, appErrStatus(e, opts).Proto())
+	}
 
-		return st.Err()
+	return withDetails(st, details...)
+}
 
-	} else {
-		return status.New(codes.Unknown, err.Error()).Err()
+// withDetails attaches details skipping those which cannot be marshaled
+func withDetails(st *status.Status, details ...proto.Message) *status.Status {
+	for _, d := range details {
+		if s, err := st.WithDetails(d); err == nil {
+			st = s
+		}
 	}
+	return st
 }
 
-//toAppError converts gRPC status to AppError
+// errorInfo builds ErrorInfo with error code as a reason
+// scalar fields, HTTP status and codes of causes are put to metadata
+func errorInfo(appErr *er.AppError, domain string) *errdetails.ErrorInfo {
+	info := &errdetails.ErrorInfo{
+		Reason:   appErr.Code(),
+		Domain:   domain,
+		Metadata: make(map[string]string),
+	}
+	for k, v := range appErr.Fields() {
+		if v == nil {
+			continue
+		}
+		switch reflect.TypeOf(v).Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Ptr, reflect.Interface:
+			continue
+		}
+		info.Metadata[k] = fmt.Sprint(v)
+	}
+	if st := appErr.HttpStatus(); st != nil {
+		info.Metadata[errInfoHttpStatus] = strconv.Itoa(int(*st))
+	}
+	var causes []string
+	for e := errors.Unwrap(appErr); e != nil; e = errors.Unwrap(e) {
+		if a, ok := e.(*er.AppError); ok {
+			causes = append(causes, a.Code())
+		}
+	}
+	if len(causes) > 0 {
+		info.Metadata[errInfoCauses] = strings.Join(causes, ",")
+	}
+	return info
+}
+
+// badRequest builds field violations from the error and aggregated errors having er.FieldKeyField populated
+func badRequest(appErr *er.AppError) *errdetails.BadRequest {
+	var violations []*errdetails.BadRequest_FieldViolation
+	if f, ok := appErr.Fields()[er.FieldKeyField].(string); ok {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: f, Description: appErr.Message()})
+	}
+	for _, e := range appErr.Errors() {
+		if a, ok := er.Is(e); ok {
+			if f, ok := a.Fields()[er.FieldKeyField].(string); ok {
+				violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: f, Description: a.Message()})
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &errdetails.BadRequest{FieldViolations: violations}
+}
+
+// toAppError converts gRPC status to AppError
+// statuses without AppErrorDetails are converted only if they have ErrorInfo or BadRequest details (e.g. sent by non-kit services),
+// otherwise the status error is returned as is
 func toAppError(err error) error {
 
-	res := err
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
 
-	st := status.Convert(err)
-	details := st.Details()
-	if len(details) > 0 {
-		errDet := details[0]
-		if appErrMsg, ok := errDet.(*AppErrorDetails); ok {
+	var (
+		appErrDet  *AppErrorDetails
+		info       *errdetails.ErrorInfo
+		br         *errdetails.BadRequest
+		retryAfter *time.Duration
+		errs       []error
+	)
 
-			var ff er.FF
-			if e := json.Unmarshal(appErrMsg.Fields, &ff); e == nil {
-				res = er.WithBuilder(appErrMsg.Code, st.Message()).F(ff).GrpcSt(uint32(st.Code())).Err()
+	for _, d := range st.Details() {
+		switch det := d.(type) {
+		case *AppErrorDetails:
+			if appErrDet == nil {
+				appErrDet = det
+			}
+		case *errdetails.ErrorInfo:
+			info = det
+		case *errdetails.BadRequest:
+			br = det
+		case *errdetails.RetryInfo:
+			if det.RetryDelay != nil {
+				d := det.RetryDelay.AsDuration()
+				retryAfter = &d
 			}
+		case *spb.Status:
+			// nested statuses are aggregated errors
+			errs = append(errs, toAppError(status.FromProto(det).Err()))
+		}
+	}
 
+	var code string
+	ff := er.FF{}
+	switch {
+	case appErrDet != nil:
+		code = appErrDet.Code
+		if e := json.Unmarshal(appErrDet.Fields, &ff); e != nil {
+			return err
+		}
+		// remote fields may be null
+		if ff == nil {
+			ff = er.FF{}
+		}
+	case info != nil:
+		code = info.Reason
+		for k, v := range info.Metadata {
+			if k != errInfoHttpStatus && k != errInfoCauses {
+				ff[k] = v
+			}
+		}
+	case br != nil:
+		code = ErrCodeGrpcRemote
+	default:
+		return err
+	}
+
+	// field violations of non-kit services are converted to aggregated errors
+	if appErrDet == nil && br != nil && len(errs) == 0 {
+		for _, v := range br.FieldViolations {
+			errs = append(errs, er.WithBuilder(ErrCodeGrpcRemote, "%s", v.Description).F(er.FF{er.FieldKeyField: v.Field}).Err())
 		}
 	}
 
-	return res
+	var b er.AppErrBuilder
+	if len(errs) > 0 {
+		b = er.Aggregate(errs, code, "%s", st.Message())
+	} else {
+		b = er.WithBuilder(code, "%s", st.Message())
+	}
+	b.GrpcSt(uint32(st.Code()))
+
+	if retryAfter != nil {
+		ff[er.FieldKeyRetryAfter] = *retryAfter
+	}
+	b.F(ff)
 
+	if info != nil {
+		if v, ok := info.Metadata[errInfoHttpStatus]; ok {
+			if httpSt, e := strconv.Atoi(v); e == nil {
+				b.HttpSt(uint32(httpSt))
+			}
+		}
+	}
+
+	// errors of non-kit services keep the original status error as a cause
+	if v := info.GetMetadata()[errInfoCauses]; v != "" {
+		b.Cause(remoteCauses(strings.Split(v, ",")))
+	} else if appErrDet == nil {
+		b.Cause(err)
+	}
+
+	return b.Err()
+}
+
+// remoteCauses restores chain of causes by their codes, so that errors.Is(err, er.Code(...)) works on client side
+func remoteCauses(codes []string) error {
+	var cause error
+	for i := len(codes) - 1; i >= 0; i-- {
+		cause = er.WithBuilder(codes[i], "").Cause(cause).Err()
+	}
+	return cause
 }
@@ -0,0 +1,163 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	src := er.WithBuilder("TST-001", "not found %s", "item").F(er.FF{"id": "123"}).GrpcSt(uint32(codes.NotFound)).HttpSt(http.StatusNotFound).Err()
+
+	res := toAppError(toGrpcStatus(src, &statusOptions{domain: "test"}))
+
+	appErr, ok := er.Is(res)
+	if !ok {
+		t.Fatalf("app error expected, got %v", res)
+	}
+	if appErr.Code() != "TST-001" {
+		t.Fatalf("unexpected code %s", appErr.Code())
+	}
+	if appErr.Message() != "not found item" {
+		t.Fatalf("unexpected message %s", appErr.Message())
+	}
+	if appErr.Fields()["id"] != "123" {
+		t.Fatalf("unexpected fields %v", appErr.Fields())
+	}
+	if st := appErr.GrpcStatus(); st == nil || codes.Code(*st) != codes.NotFound {
+		t.Fatalf("unexpected grpc status %v", st)
+	}
+	if st := appErr.HttpStatus(); st == nil || *st != http.StatusNotFound {
+		t.Fatalf("unexpected http status %v", st)
+	}
+}
+
+func Test_GrpcCodeFromHttpStatus(t *testing.T) {
+	src := er.WithBuilder("TST-001", "conflict").HttpSt(http.StatusConflict).Err()
+	if c := status.Code(toGrpcStatus(src, nil)); c != codes.Aborted {
+		t.Fatalf("unexpected code %v", c)
+	}
+}
+
+func Test_RoundTripCauses(t *testing.T) {
+	src := er.WrapWithBuilder(er.New("TST-001", "inner"), "TST-002", "outer").Err()
+
+	res := toAppError(toGrpcStatus(src, nil))
+
+	if !errors.Is(res, er.Code("TST-002")) || !errors.Is(res, er.Code("TST-001")) {
+		t.Fatalf("cause chain isn't restored: %v", res)
+	}
+}
+
+func Test_RoundTripAggregate(t *testing.T) {
+	src := er.Aggregate([]error{
+		er.WithBuilder("TST-001", "required").F(er.FF{er.FieldKeyField: "name"}).Err(),
+		er.WithBuilder("TST-002", "too long").F(er.FF{er.FieldKeyField: "description"}).Err(),
+	}, "TST-100", "validation failed").HttpSt(http.StatusBadRequest).F(er.FF{er.FieldKeyRetryAfter: time.Second}).Err()
+
+	grpcErr := toGrpcStatus(src, &statusOptions{debug: true})
+
+	st := status.Convert(grpcErr)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("unexpected code %v", st.Code())
+	}
+	var br *errdetails.BadRequest
+	var debug *errdetails.DebugInfo
+	for _, d := range st.Details() {
+		switch det := d.(type) {
+		case *errdetails.BadRequest:
+			br = det
+		case *errdetails.DebugInfo:
+			debug = det
+		}
+	}
+	if br == nil || len(br.FieldViolations) != 2 || br.FieldViolations[0].Field != "name" {
+		t.Fatalf("unexpected bad request details %v", br)
+	}
+	if debug == nil {
+		t.Fatal("debug info expected")
+	}
+
+	appErr, ok := er.Is(toAppError(grpcErr))
+	if !ok {
+		t.Fatal("app error expected")
+	}
+	if len(appErr.Errors()) != 2 {
+		t.Fatalf("unexpected aggregated errors %v", appErr.Errors())
+	}
+	if child, ok := er.Is(appErr.Errors()[1]); !ok || child.Code() != "TST-002" || child.Fields()[er.FieldKeyField] != "description" {
+		t.Fatalf("unexpected aggregated error %v", appErr.Errors()[1])
+	}
+	if d, ok := appErr.Fields()[er.FieldKeyRetryAfter].(time.Duration); !ok || d != time.Second {
+		t.Fatalf("unexpected retry after %v", appErr.Fields()[er.FieldKeyRetryAfter])
+	}
+}
+
+func Test_NoDebugByDefault(t *testing.T) {
+	st := status.Convert(toGrpcStatus(er.New("TST-001", "test"), &statusOptions{}))
+	for _, d := range st.Details() {
+		if _, ok := d.(*errdetails.DebugInfo); ok {
+			t.Fatal("debug info isn't expected")
+		}
+	}
+	if st.Message() != "test" {
+		t.Fatalf("unexpected message %s", st.Message())
+	}
+	if st := status.Convert(toGrpcStatus(errors.New("plain"), nil)); st.Message() != "plain" {
+		t.Fatalf("unexpected message %s", st.Message())
+	}
+
+	// messages of errors marked as internal aren't exposed
+	for _, err := range []error{
+		ErrGrpcSrvPanic(context.Background(), "secret"),
+		er.WithBuilder("TST-001", "secret").HttpSt(http.StatusServiceUnavailable).Err(),
+	} {
+		if st := status.Convert(toGrpcStatus(err, nil)); st.Message() != codes.Internal.String() {
+			t.Fatalf("message of internal error mustn't be exposed, got %s", st.Message())
+		}
+		if st := status.Convert(toGrpcStatus(err, &statusOptions{debug: true})); !strings.Contains(st.Message(), "secret") {
+			t.Fatalf("message of internal error is expected in debug mode, got %s", st.Message())
+		}
+	}
+}
+
+func Test_NonAppStatus(t *testing.T) {
+	plain := status.Error(codes.Unavailable, "unavailable")
+	if res := toAppError(plain); res != plain {
+		t.Fatalf("plain status must be returned as is, got %v", res)
+	}
+
+	st, _ := status.New(codes.InvalidArgument, "invalid").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "name", Description: "required"}},
+	})
+	appErr, ok := er.Is(toAppError(st.Err()))
+	if !ok {
+		t.Fatal("app error expected")
+	}
+	if appErr.Code() != ErrCodeGrpcRemote || len(appErr.Errors()) != 1 {
+		t.Fatalf("unexpected error %v", appErr)
+	}
+}
+
+func Test_NullFields(t *testing.T) {
+	st, _ := status.New(codes.Unavailable, "unavailable").WithDetails(
+		&AppErrorDetails{Code: "TST-001", Fields: []byte("null")},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)},
+	)
+	appErr, ok := er.Is(toAppError(st.Err()))
+	if !ok {
+		t.Fatal("app error expected")
+	}
+	if d, ok := appErr.Fields()[er.FieldKeyRetryAfter].(time.Duration); !ok || d != time.Second {
+		t.Fatalf("unexpected retry after %v", appErr.Fields()[er.FieldKeyRetryAfter])
+	}
+}
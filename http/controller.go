@@ -9,12 +9,15 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
 	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"git.jetbrains.space/orbi/fcsd/kit/validation"
 	"github.com/gorilla/mux"
 )
 
@@ -23,14 +26,27 @@ type Error struct {
 	Code    string                 `json:"code,omitempty"`    // Code is error code provided by error producer
 	Message string                 `json:"message"`           // Message is error description
 	Details map[string]interface{} `json:"details,omitempty"` // Details is additional info provided by error producer
+	Errors  []*Error               `json:"errors,omitempty"`  // Errors is a list of aggregated errors (e.g. validation failures)
 }
 
 func (e *Error) Error() string {
 	return fmt.Sprintf("%s:%s", e.Code, e.Message)
 }
 
+// Problem is RFC 7807 problem details object
+type Problem struct {
+	Type    string                 `json:"type"`              // Type is URI reference identifying the problem type
+	Title   string                 `json:"title"`             // Title is short summary of the problem type
+	Status  int                    `json:"status"`            // Status is HTTP status
+	Detail  string                 `json:"detail,omitempty"`  // Detail is explanation of the problem occurrence
+	Code    string                 `json:"code,omitempty"`    // Code is error code provided by error producer
+	Details map[string]interface{} `json:"details,omitempty"` // Details is additional info provided by error producer
+	Errors  []*Error               `json:"errors,omitempty"`  // Errors is a list of aggregated errors (e.g. validation failures)
+}
+
 const (
-	Me = "me" // Me can be used in URL whenever userId is expected. When encountered, userId from the session context is used
+	Me                     = "me"                       // Me can be used in URL whenever userId is expected. When encountered, userId from the session context is used
+	ContentTypeProblemJson = "application/problem+json" // ContentTypeProblemJson is RFC 7807 content type
 )
 
 var EmptyOkResponse = struct {
@@ -39,7 +55,14 @@ var EmptyOkResponse = struct {
 	Status: "OK",
 }
 
-type BaseController struct{}
+type BaseController struct {
+	// Debug - if true, messages and details of 5xx errors are exposed to clients
+	Debug bool
+	// ProblemJson - if true, errors are rendered as RFC 7807 application/problem+json
+	ProblemJson bool
+	// ProblemTypeUri - if set, problem type is built as ProblemTypeUri + error code, otherwise "about:blank"
+	ProblemTypeUri string
+}
 
 var MediaContentTypes = [...]string{
 	"image/jpeg",
@@ -177,20 +200,77 @@ func (c *BaseController) RespondJson(w http.ResponseWriter, httpStatus int, payl
 	_, _ = w.Write(response)
 }
 
+// RespondError responds with error
+// HTTP status is taken from the error (see HttpStatus), for 5xx errors in production mode message and details are hidden
 func (c *BaseController) RespondError(w http.ResponseWriter, err error) {
+	c.respondError(w, err)
+}
 
-	httpErr := &Error{}
-	httpStatus := http.StatusInternalServerError
+// RespondLocalizedError responds with error message translated to the language requested in Accept-Language header
+// translations are taken from the error catalog (see er.AddTranslations)
+func (c *BaseController) RespondLocalizedError(w http.ResponseWriter, r *http.Request, err error) {
+	c.respondError(w, err, AcceptLanguages(r)...)
+}
+
+func (c *BaseController) respondError(w http.ResponseWriter, err error, locales ...string) {
+
+	metrics.Error(err, metrics.SourceHttp)
 
+	httpErr := toHttpError(err, locales...)
+	httpStatus := HttpStatus(err)
+
+	if !c.Debug && httpStatus >= http.StatusInternalServerError {
+		httpErr.Message = http.StatusText(httpStatus)
+		httpErr.Details = nil
+		httpErr.Errors = nil
+	}
+
+	if c.ProblemJson {
+		c.respondProblem(w, httpStatus, httpErr)
+		return
+	}
+
+	c.RespondJson(w, httpStatus, httpErr)
+}
+
+// toHttpError converts error to HTTP error object, aggregated errors are converted recursively
+func toHttpError(err error, locales ...string) *Error {
+	httpErr := &Error{}
 	// check if this is an app error
 	if appErr, ok := er.Is(err); ok {
 		httpErr.Code = appErr.Code()
 		httpErr.Message = appErr.Message()
+		if len(locales) > 0 {
+			httpErr.Message = er.Translate(appErr, locales...)
+		}
 		httpErr.Details = appErr.Fields()
+		for _, e := range appErr.Errors() {
+			httpErr.Errors = append(httpErr.Errors, toHttpError(e, locales...))
+		}
 	} else {
 		httpErr.Message = err.Error()
 	}
-	c.RespondJson(w, httpStatus, httpErr)
+	return httpErr
+}
+
+// respondProblem responds with RFC 7807 problem details
+func (c *BaseController) respondProblem(w http.ResponseWriter, httpStatus int, httpErr *Error) {
+	problem := &Problem{
+		Type:    "about:blank",
+		Title:   http.StatusText(httpStatus),
+		Status:  httpStatus,
+		Detail:  httpErr.Message,
+		Code:    httpErr.Code,
+		Details: httpErr.Details,
+		Errors:  httpErr.Errors,
+	}
+	if c.ProblemTypeUri != "" && httpErr.Code != "" {
+		problem.Type = c.ProblemTypeUri + httpErr.Code
+	}
+	response, _ := json.Marshal(problem)
+	w.Header().Set("Content-Type", ContentTypeProblemJson)
+	w.WriteHeader(httpStatus)
+	_, _ = w.Write(response)
 }
 
 func (c *BaseController) RespondWithStatus(w http.ResponseWriter, status int, payload interface{}) {
@@ -209,6 +289,12 @@ func (c *BaseController) DecodeRequest(r *http.Request, ctx context.Context, bod
 	return nil
 }
 
+// DecodeAndValidate decodes JSON body and validates it by tags and Validate() method if implemented
+// validation failures are returned as a single AppError with HTTP 400 carrying field errors
+func (c *BaseController) DecodeAndValidate(r *http.Request, ctx context.Context, body interface{}) error {
+	return validation.DecodeAndValidate(r, ctx, body)
+}
+
 func (c *BaseController) Var(r *http.Request, ctx context.Context, varName string, allowEmpty bool) (string, error) {
 	if val, ok := mux.Vars(r)[varName]; ok {
 		if !allowEmpty && val == "" {
@@ -286,3 +372,37 @@ func (c *BaseController) FormValTime(r *http.Request, ctx context.Context, name
 	}
 	return &valTime, nil
 }
+
+// AcceptLanguages returns languages from Accept-Language header ordered by quality
+func AcceptLanguages(r *http.Request) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		l := lang{tag: part, q: 1}
+		if i := strings.Index(part, ";"); i >= 0 {
+			l.tag = strings.TrimSpace(part[:i])
+			if q := strings.TrimSpace(part[i+1:]); strings.HasPrefix(q, "q=") {
+				if v, err := strconv.ParseFloat(q[2:], 64); err == nil {
+					l.q = v
+				}
+			}
+		}
+		if l.tag == "*" || l.q <= 0 {
+			continue
+		}
+		langs = append(langs, l)
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	res := make([]string, 0, len(langs))
+	for _, l := range langs {
+		res = append(res, l.tag)
+	}
+	return res
+}
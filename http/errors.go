@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"git.jetbrains.space/orbi/fcsd/kit/er"
@@ -26,62 +27,134 @@ const (
 	ErrCodeHttpMultipartNext                 = "HTTP-016"
 	ErrCodeHttpMultipartFormNameFileExpected = "HTTP-017"
 	ErrCodeHttpMultipartFilename             = "HTTP-018"
+	ErrCodeHttpPagingInvalidParam            = "HTTP-019"
+	ErrCodeHttpFilterInvalidParam            = "HTTP-020"
+	ErrCodeHttpFilterFieldNotAllowed         = "HTTP-021"
+	ErrCodeHttpFilterOpNotAllowed            = "HTTP-022"
+	ErrCodeHttpFilterInvalidValue            = "HTTP-023"
+	ErrCodeHttpSrvTls                        = "HTTP-024"
+	ErrCodeHttpSrvPanic                      = "HTTP-025"
+	ErrCodeHttpTenantInvalid                 = "HTTP-026"
+	ErrCodeHttpTenantMismatch                = "HTTP-027"
+	ErrCodeHttpTenantNotAllowed              = "HTTP-028"
 )
 
 var (
-	ErrHttpSrvListen     = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeHttpSrvListen, "").Err() }
+	ErrHttpSrvListen     = func(cause error) error { return er.WrapWithCode(cause, ErrCodeHttpSrvListen).Err() }
 	ErrHttpDecodeRequest = func(cause error, ctx context.Context) error {
-		return er.WrapWithBuilder(cause, ErrCodeDecodeRequest, "invalid request").C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WrapWithCode(cause, ErrCodeDecodeRequest).C(ctx).Err()
 	}
 	ErrHttpUrlVar = func(ctx context.Context, v string) error {
-		return er.WithBuilder(ErrCodeHttpUrlVar, "invalid or empty URL parameter").F(er.FF{"var": v}).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpUrlVar).F(er.FF{"var": v}).C(ctx).Err()
 	}
 	ErrHttpCurrentUser = func(ctx context.Context) error {
-		return er.WithBuilder(ErrCodeHttpCurrentUser, `cannot obtain current user`).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpCurrentUser).C(ctx).Err()
 	}
+	// ErrHttpCurrentSession shares the code with ErrHttpCurrentUser for compatibility, so its message isn't taken from the catalog
 	ErrHttpCurrentSession = func(ctx context.Context) error {
-		return er.WithBuilder(ErrCodeHttpCurrentUser, `cannot obtain current session`).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithBuilder(ErrCodeHttpCurrentUser, "cannot obtain current session").C(ctx).HttpSt(http.StatusBadRequest).Err()
 	}
 	ErrHttpUrlVarEmpty = func(ctx context.Context, v string) error {
-		return er.WithBuilder(ErrCodeHttpUrlVarEmpty, `URL parameter is empty`).F(er.FF{"var": v}).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpUrlVarEmpty).F(er.FF{"var": v}).C(ctx).Err()
 	}
 	ErrHttpUrlFormVarEmpty = func(ctx context.Context, v string) error {
-		return er.WithBuilder(ErrCodeHttpUrlFormVarEmpty, `URL form value is empty`).F(er.FF{"var": v}).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpUrlFormVarEmpty).F(er.FF{"var": v}).C(ctx).Err()
 	}
 	ErrHttpUrlFormVarNotInt = func(cause error, ctx context.Context, v string) error {
-		return er.WrapWithBuilder(cause, ErrCodeHttpUrlFormVarNotInt, "form value must be of int type").C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WrapWithCode(cause, ErrCodeHttpUrlFormVarNotInt).C(ctx).Err()
 	}
 	ErrHttpUrlFormVarNotTime = func(cause error, ctx context.Context, v string) error {
-		return er.WrapWithBuilder(cause, ErrCodeHttpUrlFormVarNotTime, "form value must be of time type in RFC-3339 format").C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WrapWithCode(cause, ErrCodeHttpUrlFormVarNotTime).C(ctx).Err()
 	}
 	ErrHttpMultipartParseForm = func(cause error, ctx context.Context) error {
-		return er.WrapWithBuilder(cause, ErrCodeHttpMultipartParseForm, "parse multipart form").C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WrapWithCode(cause, ErrCodeHttpMultipartParseForm).C(ctx).Err()
 	}
 	ErrHttpMultipartEmptyContent = func(ctx context.Context) error {
-		return er.WithBuilder(ErrCodeHttpMultipartEmptyContent, `content is empty`).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpMultipartEmptyContent).C(ctx).Err()
 	}
 	ErrHttpMultipartNotMultipart = func(ctx context.Context) error {
-		return er.WithBuilder(ErrCodeHttpMultipartNotMultipart, `content isn't multipart`).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpMultipartNotMultipart).C(ctx).Err()
 	}
 	ErrHttpMultipartParseMediaType = func(cause error, ctx context.Context) error {
-		return er.WrapWithBuilder(cause, ErrCodeHttpMultipartParseMediaType, "parse media type").C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WrapWithCode(cause, ErrCodeHttpMultipartParseMediaType).C(ctx).Err()
 	}
 	ErrHttpMultipartWrongMediaType = func(ctx context.Context, mt string) error {
-		return er.WithBuilder(ErrCodeHttpMultipartWrongMediaType, `wrong media type %s`, mt).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpMultipartWrongMediaType, mt).C(ctx).Err()
 	}
 	ErrHttpMultipartMissingBoundary = func(ctx context.Context) error {
-		return er.WithBuilder(ErrCodeHttpMultipartMissingBoundary, `missing boundary`).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpMultipartMissingBoundary).C(ctx).Err()
 	}
 	ErrHttpMultipartEofReached = func(ctx context.Context) error {
-		return er.WithBuilder(ErrCodeHttpMultipartEofReached, `no parts found`).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpMultipartEofReached).C(ctx).Err()
 	}
 	ErrHttpMultipartNext = func(cause error, ctx context.Context) error {
-		return er.WrapWithBuilder(cause, ErrCodeHttpMultipartNext, "reading part").C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WrapWithCode(cause, ErrCodeHttpMultipartNext).C(ctx).Err()
 	}
 	ErrHttpMultipartFormNameFileExpected = func(ctx context.Context) error {
-		return er.WithBuilder(ErrCodeHttpMultipartFormNameFileExpected, `correct part must have name="file" param`).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpMultipartFormNameFileExpected).C(ctx).Err()
 	}
 	ErrHttpMultipartFilename = func(ctx context.Context) error {
-		return er.WithBuilder(ErrCodeHttpMultipartFilename, `filename is empty`).C(ctx).HttpSt(http.StatusBadRequest).Err()
+		return er.WithCode(ErrCodeHttpMultipartFilename).C(ctx).Err()
+	}
+	ErrHttpPagingInvalidParam = func(ctx context.Context, v string) error {
+		return er.WithCode(ErrCodeHttpPagingInvalidParam).F(er.FF{"var": v}).C(ctx).Err()
+	}
+	ErrHttpFilterInvalidParam = func(ctx context.Context, v string) error {
+		return er.WithCode(ErrCodeHttpFilterInvalidParam).F(er.FF{"var": v}).C(ctx).Err()
+	}
+	ErrHttpFilterFieldNotAllowed = func(ctx context.Context, field string) error {
+		return er.WithCode(ErrCodeHttpFilterFieldNotAllowed).F(er.FF{"field": field}).C(ctx).Err()
+	}
+	ErrHttpFilterOpNotAllowed = func(ctx context.Context, field, op string) error {
+		return er.WithCode(ErrCodeHttpFilterOpNotAllowed).F(er.FF{"field": field, "op": op}).C(ctx).Err()
+	}
+	ErrHttpFilterInvalidValue = func(cause error, ctx context.Context, field string) error {
+		return er.WrapWithCode(cause, ErrCodeHttpFilterInvalidValue).F(er.FF{"field": field}).C(ctx).Err()
+	}
+	ErrHttpSrvTls   = func(cause error) error { return er.WrapWithCode(cause, ErrCodeHttpSrvTls).Err() }
+	ErrHttpSrvPanic = func(ctx context.Context, r interface{}) error {
+		return er.WithCode(ErrCodeHttpSrvPanic, fmt.Sprint(r)).C(ctx).Err()
+	}
+	ErrHttpTenantInvalid = func(ctx context.Context) error {
+		return er.WithCode(ErrCodeHttpTenantInvalid).C(ctx).Err()
+	}
+	ErrHttpTenantMismatch = func(ctx context.Context, header, subdomain string) error {
+		return er.WithCode(ErrCodeHttpTenantMismatch).F(er.FF{"header": header, "subdomain": subdomain}).C(ctx).Err()
+	}
+	ErrHttpTenantNotAllowed = func(cause error, ctx context.Context, tenantId string) error {
+		return er.WithCode(ErrCodeHttpTenantNotAllowed).Cause(cause).F(er.FF{"tenant": tenantId}).C(ctx).Err()
 	}
 )
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeHttpSrvListen, Message: "http server listen failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeDecodeRequest, Message: "invalid request", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpUrlVar, Message: "invalid or empty URL parameter", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpCurrentUser, Message: "cannot obtain current user", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpUrlVarEmpty, Message: "URL parameter is empty", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpUrlFormVarEmpty, Message: "URL form value is empty", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpUrlFormVarNotInt, Message: "form value must be of int type", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpUrlFormVarNotTime, Message: "form value must be of time type in RFC-3339 format", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartParseForm, Message: "parse multipart form", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartEmptyContent, Message: "content is empty", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartNotMultipart, Message: "content isn't multipart", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartParseMediaType, Message: "parse media type", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartWrongMediaType, Message: "wrong media type %s", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartMissingBoundary, Message: "missing boundary", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartEofReached, Message: "no parts found", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartNext, Message: "reading part", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartFormNameFileExpected, Message: `correct part must have name="file" param`, HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpMultipartFilename, Message: "filename is empty", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpPagingInvalidParam, Message: "invalid paging parameter", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpFilterInvalidParam, Message: "invalid filter parameter", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpFilterFieldNotAllowed, Message: "filtering by field isn't allowed", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpFilterOpNotAllowed, Message: "filter operator isn't allowed", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpFilterInvalidValue, Message: "invalid filter value", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpSrvTls, Message: "load server tls", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeHttpSrvPanic, Message: "panic: %s", HttpStatus: http.StatusInternalServerError, Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeHttpTenantInvalid, Message: "invalid tenant id", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpTenantMismatch, Message: "tenant ids of header and subdomain differ", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeHttpTenantNotAllowed, Message: "tenant isn't allowed on public route", HttpStatus: http.StatusForbidden, Severity: er.SeverityWarning},
+	)
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"git.jetbrains.space/orbi/fcsd/kit/tracing"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RouteUnmatched is a route label of requests not matched by router
+const RouteUnmatched = "unmatched"
+
+// statusRecorder captures response status and size
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack is required by websocket upgrader
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		if r.status == 0 {
+			r.status = http.StatusSwitchingProtocols
+		}
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("hijacking isn't supported")
+}
+
+// routeHolder is filled with the route template by the router, so that outer handlers can access it
+type routeHolder struct {
+	template string
+}
+
+type routeHolderKey struct{}
+
+// routeMiddleware is applied to the root router and stores matched route template
+func routeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := r.Context().Value(routeHolderKey{}).(*routeHolder); ok {
+			if route := mux.CurrentRoute(r); route != nil {
+				if tmpl, err := route.GetPathTemplate(); err == nil {
+					h.template = tmpl
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// instrumentHandler logs requests, recovers panics, collects metrics and traces requests as configured
+func (s *Server) instrumentHandler(next http.Handler) http.Handler {
+
+	c := &BaseController{Debug: s.config.DebugErrors}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		route := &routeHolder{template: RouteUnmatched}
+		ctx := context.WithValue(r.Context(), routeHolderKey{}, route)
+
+		// span is named by route template when route is matched
+		var span trace.Span
+		if s.config.Tracing {
+			ctx = tracing.Extract(ctx, propagation.HeaderCarrier(r.Header))
+			ctx, span = tracing.Start(ctx, "HTTP "+r.Method, trace.WithSpanKind(trace.SpanKindServer))
+		}
+
+		r = r.WithContext(ctx)
+		rec := &statusRecorder{ResponseWriter: w}
+
+		defer func() {
+			if s.config.Recovery {
+				if p := recover(); p != nil {
+					if p == http.ErrAbortHandler {
+						panic(p)
+					}
+					err := ErrHttpSrvPanic(r.Context(), p)
+					s.logger().Pr("http").Cmp("server").Mth("recovery").C(r.Context()).E(err).St().Err()
+					if rec.status == 0 {
+						c.RespondError(rec, err)
+					}
+				}
+			}
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			duration := time.Since(start)
+
+			if s.config.Metrics {
+				metrics.HttpRequests.WithLabelValues(r.Method, route.template, strconv.Itoa(status)).Inc()
+				metrics.HttpRequestDuration.WithLabelValues(r.Method, route.template).Observe(duration.Seconds())
+			}
+
+			if span != nil {
+				span.SetName(r.Method + " " + route.template)
+				var err error
+				if status >= http.StatusInternalServerError {
+					err = errors.New(http.StatusText(status))
+				}
+				tracing.End(span, err,
+					semconv.HTTPMethodKey.String(r.Method),
+					semconv.HTTPRouteKey.String(route.template),
+					semconv.HTTPTargetKey.String(r.URL.Path),
+					semconv.HTTPStatusCodeKey.Int(status))
+			}
+
+			if s.config.AccessLog {
+				s.logger().Pr("http").Cmp("server").Mth("access").C(r.Context()).F(log.FF{
+					"method":      r.Method,
+					"route":       route.template,
+					"path":        r.URL.Path,
+					"status":      status,
+					"bytes":       rec.bytes,
+					"duration-ms": duration.Milliseconds(),
+				}).Inf("request")
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
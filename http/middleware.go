@@ -0,0 +1,88 @@
+package http
+
+import (
+	"net/http"
+	"regexp"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	HeaderRequestId     = "X-Request-Id"
+	HeaderSessionId     = "X-Session-Id"
+	HeaderUserSessionId = "X-User-Session-Id"
+)
+
+// validRequestId restricts incoming request ids to prevent log injection
+var validRequestId = regexp.MustCompile(`^[a-zA-Z0-9._\-]{1,128}$`)
+
+// RequestContextConfig specifies where request context params are taken from
+// empty header or cookie names are replaced with defaults
+type RequestContextConfig struct {
+	// RequestIdHeader - request id header, X-Request-Id by default
+	RequestIdHeader string `config:"request-id-header"`
+	// SessionIdHeader, SessionIdCookie - session id header (X-Session-Id by default) and cookie (not used by default)
+	SessionIdHeader string `config:"session-id-header"`
+	SessionIdCookie string `config:"session-id-cookie"`
+	// UserSessionIdHeader, UserSessionIdCookie - user session id header (X-User-Session-Id by default) and cookie (not used by default)
+	UserSessionIdHeader string `config:"user-session-id-header"`
+	UserSessionIdCookie string `config:"user-session-id-cookie"`
+}
+
+func (c *RequestContextConfig) withDefaults() *RequestContextConfig {
+	res := &RequestContextConfig{}
+	if c != nil {
+		*res = *c
+	}
+	if res.RequestIdHeader == "" {
+		res.RequestIdHeader = HeaderRequestId
+	}
+	if res.SessionIdHeader == "" {
+		res.SessionIdHeader = HeaderSessionId
+	}
+	if res.UserSessionIdHeader == "" {
+		res.UserSessionIdHeader = HeaderUserSessionId
+	}
+	return res
+}
+
+// RequestContextMiddleware builds request context from the request
+// request id is taken from the header or generated and echoed in response, client type is "ws" for websocket upgrades and "rest" otherwise
+// request context is propagated to outgoing gRPC calls by the kit gRPC client
+func RequestContextMiddleware(cfg *RequestContextConfig) func(http.Handler) http.Handler {
+	cfg = cfg.withDefaults()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			rCtx := kitContext.NewRequestCtx().Rest()
+			if websocket.IsWebSocketUpgrade(r) {
+				rCtx = rCtx.Ws()
+			}
+
+			if rid := r.Header.Get(cfg.RequestIdHeader); validRequestId.MatchString(rid) {
+				rCtx = rCtx.WithRequestId(rid)
+			} else {
+				rCtx = rCtx.WithNewRequestId()
+			}
+			rCtx = rCtx.WithSessionId(headerOrCookie(r, cfg.SessionIdHeader, cfg.SessionIdCookie)).
+				WithUserSession(headerOrCookie(r, cfg.UserSessionIdHeader, cfg.UserSessionIdCookie))
+
+			w.Header().Set(cfg.RequestIdHeader, rCtx.GetRequestId())
+
+			next.ServeHTTP(w, r.WithContext(rCtx.ToContext(r.Context())))
+		})
+	}
+}
+
+func headerOrCookie(r *http.Request, header, cookie string) string {
+	if v := r.Header.Get(header); v != "" {
+		return v
+	}
+	if cookie != "" {
+		if c, err := r.Cookie(cookie); err == nil {
+			return c.Value
+		}
+	}
+	return ""
+}
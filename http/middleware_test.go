@@ -0,0 +1,183 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_RequestContextMiddleware(t *testing.T) {
+	var rCtx *kitContext.RequestContext
+	h := RequestContextMiddleware(&RequestContextConfig{SessionIdCookie: "sid"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rCtx, _ = kitContext.Request(r.Context())
+	}))
+
+	rq := httptest.NewRequest(http.MethodGet, "/", nil)
+	rq.Header.Set(HeaderRequestId, "rid")
+	rq.Header.Set(HeaderUserSessionId, "usid")
+	rq.AddCookie(&http.Cookie{Name: "sid", Value: "sid"})
+	rs := httptest.NewRecorder()
+	h.ServeHTTP(rs, rq)
+	if rCtx == nil || rCtx.GetRequestId() != "rid" || rCtx.GetSessionId() != "sid" || rCtx.GetUserSessionId() != "usid" || rCtx.GetClientType() != kitContext.CLIENT_TYPE_REST {
+		t.Fatalf("unexpected request context %+v", rCtx)
+	}
+	if rs.Header().Get(HeaderRequestId) != "rid" {
+		t.Fatal("request id isn't echoed")
+	}
+
+	// invalid request id is replaced, websocket client type is detected
+	rq = httptest.NewRequest(http.MethodGet, "/", nil)
+	rq.Header.Set(HeaderRequestId, "bad\nid")
+	rq.Header.Set("Connection", "Upgrade")
+	rq.Header.Set("Upgrade", "websocket")
+	h.ServeHTTP(httptest.NewRecorder(), rq)
+	if rCtx.GetRequestId() == "" || rCtx.GetRequestId() == "bad\nid" || rCtx.GetClientType() != kitContext.CLIENT_TYPE_WS {
+		t.Fatalf("unexpected request context %+v", rCtx)
+	}
+}
+
+func Test_Instrument(t *testing.T) {
+	logger := log.Init(&log.Config{Level: log.ErrorLevel})
+	s := NewHttpServer(&Config{Recovery: true, AccessLog: true, Metrics: true}, func() log.CLogger { return log.L(logger) })
+	s.RootRouter.HandleFunc("/panic/{id}", func(w http.ResponseWriter, r *http.Request) { panic("test") })
+
+	rs := httptest.NewRecorder()
+	s.Srv.Handler.ServeHTTP(rs, httptest.NewRequest(http.MethodGet, "/panic/1", nil))
+	if rs.Code != http.StatusInternalServerError {
+		t.Fatalf("500 expected, got %d", rs.Code)
+	}
+	if strings.Contains(rs.Body.String(), "test") {
+		t.Fatalf("panic details mustn't be exposed by default: %s", rs.Body.String())
+	}
+	if rs.Header().Get(HeaderRequestId) == "" {
+		t.Fatal("request id expected")
+	}
+
+	count := testutil.ToFloat64(metrics.HttpRequests.WithLabelValues(http.MethodGet, "/panic/{id}", "500"))
+	if count != 1 {
+		t.Fatalf("unexpected requests count %v", count)
+	}
+
+	rs = httptest.NewRecorder()
+	s.Srv.Handler.ServeHTTP(rs, httptest.NewRequest(http.MethodGet, metrics.DefaultPath, nil))
+	if rs.Code != http.StatusOK || !strings.Contains(rs.Body.String(), "http_requests_total") {
+		t.Fatalf("metrics expected, got %d", rs.Code)
+	}
+}
+
+func Test_TenantMiddleware(t *testing.T) {
+	var tenantId string
+	h := TenantMiddleware(nil, &TenantConfig{Domain: "example.com"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantId, _ = kitContext.Tenant(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		host   string
+		header string
+		tenant string
+		status int
+	}{
+		{"header", "example.com", "a", "a", http.StatusOK},
+		{"subdomain", "b.example.com:8080", "", "b", http.StatusOK},
+		{"same", "a.example.com", "a", "a", http.StatusOK},
+		{"conflict", "b.example.com", "a", "", http.StatusBadRequest},
+		{"invalid", "example.com", "a:b", "", http.StatusBadRequest},
+		{"none", "other.com", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenantId = ""
+			rq := httptest.NewRequest(http.MethodGet, "/", nil)
+			rq.Host = tt.host
+			if tt.header != "" {
+				rq.Header.Set(HeaderTenantId, tt.header)
+			}
+			rs := httptest.NewRecorder()
+			h.ServeHTTP(rs, rq)
+			if rs.Code != tt.status || tenantId != tt.tenant {
+				t.Fatalf("unexpected status %d, tenant %s", rs.Code, tenantId)
+			}
+		})
+	}
+}
+
+func Test_PublicTenant(t *testing.T) {
+	logger := log.Init(&log.Config{Level: log.ErrorLevel})
+	cfg := &Config{Tenant: &TenantConfig{}}
+	var tenantId string
+	handler := func(w http.ResponseWriter, r *http.Request) { tenantId, _ = kitContext.Tenant(r.Context()) }
+
+	request := func(s *Server, path, tenant string) int {
+		tenantId = ""
+		rq := httptest.NewRequest(http.MethodGet, path, nil)
+		if tenant != "" {
+			rq.Header.Set(HeaderTenantId, tenant)
+		}
+		rs := httptest.NewRecorder()
+		s.Srv.Handler.ServeHTTP(rs, rq)
+		return rs.Code
+	}
+
+	// tenant isn't verified on public routes, so it's rejected by default
+	s := NewHttpServer(cfg, func() log.CLogger { return log.L(logger) })
+	s.NoAuthRouter.HandleFunc("/public", handler)
+	if st := request(s, "/public", "a"); st != http.StatusForbidden || tenantId != "" {
+		t.Fatalf("unexpected status %d, tenant %s", st, tenantId)
+	}
+	if st := request(s, "/public", ""); st != http.StatusOK {
+		t.Fatalf("unexpected status %d", st)
+	}
+
+	cfg.Tenant.Public = func(ctx context.Context, tenantId string) error {
+		if tenantId != "a" {
+			return errors.New("unknown tenant")
+		}
+		return nil
+	}
+	s = NewHttpServer(cfg, func() log.CLogger { return log.L(logger) })
+	s.NoAuthRouter.HandleFunc("/public", handler)
+	if st := request(s, "/public", "a"); st != http.StatusOK || tenantId != "a" {
+		t.Fatalf("unexpected status %d, tenant %s", st, tenantId)
+	}
+	if st := request(s, "/public", "b"); st != http.StatusForbidden {
+		t.Fatalf("unexpected status %d", st)
+	}
+}
+
+func Test_AcceptLanguages(t *testing.T) {
+	rq := httptest.NewRequest(http.MethodGet, "/", nil)
+	rq.Header.Set("Accept-Language", "en;q=0.5, ru-RU, *;q=0.1, de;q=0, fr;q=0.8")
+	if langs := AcceptLanguages(rq); strings.Join(langs, ",") != "ru-RU,fr,en" {
+		t.Fatalf("unexpected languages %v", langs)
+	}
+	if langs := AcceptLanguages(httptest.NewRequest(http.MethodGet, "/", nil)); len(langs) != 0 {
+		t.Fatalf("unexpected languages %v", langs)
+	}
+}
+
+func Test_ServerTls(t *testing.T) {
+	logger := log.Init(&log.Config{Level: log.ErrorLevel})
+	cfg := &Config{TlsCertFile: "not-found.crt", TlsKeyFile: "not-found.key"}
+	_, err := NewHttpServerTls(cfg, func() log.CLogger { return log.L(logger) })
+	if !errors.Is(err, er.Code(ErrCodeHttpSrvTls)) {
+		t.Fatalf("tls error expected, got %v", err)
+	}
+
+	// server is created, but it isn't started
+	s := NewHttpServer(cfg, func() log.CLogger { return log.L(logger) })
+	if !errors.Is(s.tlsErr, er.Code(ErrCodeHttpSrvTls)) {
+		t.Fatalf("tls error expected, got %v", s.tlsErr)
+	}
+	s.Listen()
+	s.Close()
+}
@@ -0,0 +1,243 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/common"
+)
+
+const (
+	QueryPage   = "page"   // QueryPage - page number starting from 1
+	QuerySize   = "size"   // QuerySize - page size
+	QuerySort   = "sort"   // QuerySort - comma separated list of fields, "-" prefix means descending order
+	QueryCursor = "cursor" // QueryCursor - cursor for keyset pagination
+	QueryFilter = "filter" // QueryFilter - filter params are specified as filter[field]=value or filter[field][op]=value
+)
+
+// filterParamRegexp matches filter[field] and filter[field][op]
+var filterParamRegexp = regexp.MustCompile(`^filter\[([^\[\]]+)\](?:\[([^\[\]]+)\])?$`)
+
+// PageResponse is a response body of paged list
+type PageResponse struct {
+	Items  interface{}   `json:"items"`
+	Paging *PageMetadata `json:"paging"`
+}
+
+// PageMetadata describes returned page
+type PageMetadata struct {
+	Index      int    `json:"index,omitempty"`
+	Size       int    `json:"size,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// PagingRequest parses paging and sorting query params, e.g. ?page=2&size=50&sort=-createdAt,name
+// sort fields aren't validated here, it's up to storage layer to check them against whitelist
+func (c *BaseController) PagingRequest(r *http.Request, ctx context.Context) (*common.PagingRequest, error) {
+
+	rq := &common.PagingRequest{}
+
+	page, err := c.FormValInt(r, ctx, QueryPage, true)
+	if err != nil {
+		return nil, err
+	}
+	if page != nil {
+		if *page < 1 {
+			return nil, ErrHttpPagingInvalidParam(ctx, QueryPage)
+		}
+		rq.Index = *page
+	}
+
+	size, err := c.FormValInt(r, ctx, QuerySize, true)
+	if err != nil {
+		return nil, err
+	}
+	if size != nil {
+		if *size < 1 {
+			return nil, ErrHttpPagingInvalidParam(ctx, QuerySize)
+		}
+		rq.Size = *size
+	}
+
+	rq.Cursor = r.URL.Query().Get(QueryCursor)
+
+	if sort := r.URL.Query().Get(QuerySort); sort != "" {
+		for _, s := range strings.Split(sort, ",") {
+			s = strings.TrimSpace(s)
+			sr := &common.SortRequest{Direction: common.SortAsc}
+			switch {
+			case strings.HasPrefix(s, "-"):
+				sr.Direction = common.SortDesc
+				s = s[1:]
+			case strings.HasPrefix(s, "+"):
+				s = s[1:]
+			}
+			if s == "" {
+				return nil, ErrHttpPagingInvalidParam(ctx, QuerySort)
+			}
+			sr.Field = s
+			rq.SortBy = append(rq.SortBy, sr)
+		}
+	}
+
+	return rq, nil
+}
+
+// Filter parses filter query params into filter AST
+// filter[status]=active is equal to filter[status][eq]=active, values of "in" operator are comma separated
+// fields and operators are validated against whitelist, values are converted to field types
+// conditions are ordered by params, the result is nil if no filter params specified
+func (c *BaseController) Filter(r *http.Request, ctx context.Context, fields common.FilterFields) (*common.FilterAnd, error) {
+
+	var res *common.FilterAnd
+
+	query := r.URL.Query()
+	var params []string
+	for param := range query {
+		if strings.HasPrefix(param, QueryFilter+"[") {
+			params = append(params, param)
+		}
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+
+		values := query[param]
+
+		m := filterParamRegexp.FindStringSubmatch(param)
+		if m == nil {
+			return nil, ErrHttpFilterInvalidParam(ctx, param)
+		}
+		field, op := m[1], m[2]
+		if op == "" {
+			op = common.FilterOpEq
+		}
+
+		ff, ok := fields[field]
+		if !ok {
+			return nil, ErrHttpFilterFieldNotAllowed(ctx, field)
+		}
+		if !ff.Allows(op) {
+			return nil, ErrHttpFilterOpNotAllowed(ctx, field, op)
+		}
+
+		for _, v := range values {
+			raw := []string{v}
+			if op == common.FilterOpIn {
+				raw = strings.Split(v, ",")
+			}
+			cond := &common.FilterCondition{Field: field, Op: op}
+			for _, rv := range raw {
+				tv, err := filterValue(ff.Type, rv)
+				if err != nil {
+					return nil, ErrHttpFilterInvalidValue(err, ctx, field)
+				}
+				cond.Values = append(cond.Values, tv)
+			}
+			if res == nil {
+				res = &common.FilterAnd{}
+			}
+			res.Exprs = append(res.Exprs, cond)
+		}
+	}
+
+	return res, nil
+}
+
+// filterValue converts raw value to the given type
+func filterValue(tp, v string) (interface{}, error) {
+	switch tp {
+	case common.FilterTypeInt:
+		return strconv.ParseInt(v, 10, 64)
+	case common.FilterTypeFloat:
+		return strconv.ParseFloat(v, 64)
+	case common.FilterTypeBool:
+		return strconv.ParseBool(v)
+	case common.FilterTypeTime:
+		return time.Parse(time.RFC3339, v)
+	case common.FilterTypeString, "":
+		return v, nil
+	default:
+		return nil, fmt.Errorf("type %s not supported", tp)
+	}
+}
+
+// RespondPage responds with a page of items adding paging metadata to the body and Link header (RFC 8288)
+// rq is a request the page was built for
+func (c *BaseController) RespondPage(w http.ResponseWriter, r *http.Request, items interface{}, rq *common.PagingRequest, rs *common.PagingResponse) {
+
+	meta := &PageMetadata{}
+	if rq != nil {
+		meta.Size = rq.Size
+	}
+	if rs != nil {
+		meta.Index = rs.Index
+		meta.NextCursor = rs.NextCursor
+		if rs.Size > 0 {
+			meta.Size = rs.Size
+		}
+		// total is known for offset pagination only
+		if rs.Index > 0 {
+			total := rs.Total
+			meta.Total = &total
+			w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		}
+	}
+
+	if links := pageLinks(r.URL, meta); len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	c.RespondOK(w, &PageResponse{Items: items, Paging: meta})
+}
+
+// pageLinks builds Link header values keeping all query params of the original request except paging ones
+func pageLinks(u *url.URL, meta *PageMetadata) []string {
+
+	link := func(rel string, set func(q url.Values)) string {
+		lu := *u
+		q := lu.Query()
+		set(q)
+		lu.RawQuery = q.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, lu.String(), rel)
+	}
+
+	var links []string
+
+	// keyset pagination
+	if meta.NextCursor != "" {
+		links = append(links, link("next", func(q url.Values) { q.Set(QueryCursor, meta.NextCursor) }))
+		return links
+	}
+
+	// offset pagination
+	if meta.Size <= 0 || meta.Index <= 0 || meta.Total == nil {
+		return links
+	}
+	last := (*meta.Total + meta.Size - 1) / meta.Size
+	if last < 1 {
+		last = 1
+	}
+	page := func(p int) func(q url.Values) {
+		return func(q url.Values) { q.Set(QueryPage, strconv.Itoa(p)) }
+	}
+
+	links = append(links, link("first", page(1)))
+	if meta.Index > 1 {
+		links = append(links, link("prev", page(meta.Index-1)))
+	}
+	if meta.Index < last {
+		links = append(links, link("next", page(meta.Index+1)))
+	}
+	links = append(links, link("last", page(last)))
+
+	return links
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"git.jetbrains.space/orbi/fcsd/kit/common"
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+)
+
+var testFilterFields = common.FilterFields{
+	"status":    {Type: common.FilterTypeString},
+	"amount":    {Type: common.FilterTypeInt},
+	"createdAt": {Type: common.FilterTypeTime},
+	"name":      {Type: common.FilterTypeString, Ops: []string{common.FilterOpEq}},
+}
+
+func Test_Filter(t *testing.T) {
+	c := &BaseController{}
+	rq := httptest.NewRequest(http.MethodGet, "/?status=x&filter[status]=active&filter[amount][in]=1,2&filter[createdAt][gte]=2021-01-02T03:04:05Z", nil)
+
+	f, err := c.Filter(rq, context.Background(), testFilterFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f == nil || len(f.Exprs) != 3 {
+		t.Fatalf("unexpected filter %+v", f)
+	}
+
+	// conditions are ordered by params
+	amount := f.Exprs[0].(*common.FilterCondition)
+	if amount.Field != "amount" || amount.Op != common.FilterOpIn || len(amount.Values) != 2 || amount.Values[1] != int64(2) {
+		t.Fatalf("unexpected condition %+v", amount)
+	}
+	createdAt := f.Exprs[1].(*common.FilterCondition)
+	if createdAt.Op != common.FilterOpGte || !createdAt.Values[0].(time.Time).Equal(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("unexpected condition %+v", createdAt)
+	}
+	status := f.Exprs[2].(*common.FilterCondition)
+	if status.Field != "status" || status.Op != common.FilterOpEq || status.Values[0] != "active" {
+		t.Fatalf("unexpected condition %+v", status)
+	}
+
+	f, err = c.Filter(httptest.NewRequest(http.MethodGet, "/?status=x", nil), context.Background(), testFilterFields)
+	if err != nil || f != nil {
+		t.Fatalf("nil filter expected, got %+v, %v", f, err)
+	}
+}
+
+func Test_FilterRejected(t *testing.T) {
+	c := &BaseController{}
+	tests := []struct {
+		query string
+		code  string
+	}{
+		{"filter[status][eq][x]=a", ErrCodeHttpFilterInvalidParam},
+		{"filter[secret]=a", ErrCodeHttpFilterFieldNotAllowed},
+		{"filter[name][like]=a", ErrCodeHttpFilterOpNotAllowed},
+		{"filter[amount][like]=a", ErrCodeHttpFilterOpNotAllowed},
+		{"filter[amount]=a", ErrCodeHttpFilterInvalidValue},
+		{"filter[createdAt][lt]=yesterday", ErrCodeHttpFilterInvalidValue},
+	}
+	for _, tt := range tests {
+		rq := httptest.NewRequest(http.MethodGet, "/?"+url.PathEscape(tt.query), nil)
+		if _, err := c.Filter(rq, context.Background(), testFilterFields); !errors.Is(err, er.Code(tt.code)) {
+			t.Fatalf("%s: %s expected, got %v", tt.query, tt.code, err)
+		}
+	}
+}
+
+func Test_PageLinks(t *testing.T) {
+	u, _ := url.Parse("/items?page=2&size=10&status=active")
+	total := 25
+
+	links := pageLinks(u, &PageMetadata{Index: 2, Size: 10, Total: &total})
+	expected := []string{
+		`</items?page=1&size=10&status=active>; rel="first"`,
+		`</items?page=1&size=10&status=active>; rel="prev"`,
+		`</items?page=3&size=10&status=active>; rel="next"`,
+		`</items?page=3&size=10&status=active>; rel="last"`,
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("unexpected links %v", links)
+	}
+	for i := range expected {
+		if links[i] != expected[i] {
+			t.Fatalf("unexpected link %s, expected %s", links[i], expected[i])
+		}
+	}
+
+	links = pageLinks(u, &PageMetadata{Index: 1, Size: 10, Total: new(int)})
+	if len(links) != 2 || links[1] != `</items?page=1&size=10&status=active>; rel="last"` {
+		t.Fatalf("unexpected links %v", links)
+	}
+
+	links = pageLinks(u, &PageMetadata{NextCursor: "abc"})
+	if len(links) != 1 || links[0] != `</items?cursor=abc&page=2&size=10&status=active>; rel="next"` {
+		t.Fatalf("unexpected links %v", links)
+	}
+}
@@ -2,7 +2,10 @@ package http
 
 import (
 	"fmt"
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
 	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"git.jetbrains.space/orbi/fcsd/kit/utils"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/rs/cors"
@@ -23,6 +26,34 @@ type Config struct {
 	AllowedHeaders []string
 	Debug          bool
 	Port           string
+	// TlsCertFile, TlsKeyFile - server certificate and key, TLS is enabled if specified
+	TlsCertFile string
+	TlsKeyFile  string
+	// TlsCaFile - CA certificates used to verify client certificates
+	TlsCaFile string
+	// TlsClientAuth - client certificate verification: "" (none), "optional", "require"
+	TlsClientAuth string
+	// TlsReloadInterval - how often certificate files are checked for changes, 1 min by default
+	TlsReloadInterval time.Duration
+	// RequestContext - how request context is built from requests, defaults are used if empty
+	RequestContext *RequestContextConfig
+	// DisableRequestContext - if true, request context isn't built by the server
+	DisableRequestContext bool
+	// Tenant - how tenant id is resolved from requests, tenant isn't resolved if empty
+	// requests with tenant to NoAuthRouter are rejected unless Tenant.Public accepts them (see PublicTenantMiddleware)
+	Tenant *TenantConfig
+	// AccessLog - if true, each request is logged with method, route, status, size and duration
+	AccessLog bool
+	// Recovery - if true, panics in handlers are logged and responded with 500
+	Recovery bool
+	// Metrics - if true, request counters and duration histograms are collected per route and kit metrics are exposed
+	Metrics bool
+	// MetricsPath - path of metrics endpoint, "/metrics" by default
+	MetricsPath string
+	// Tracing - if true, a span is created per request continuing W3C trace context from headers (see tracing.Init)
+	Tracing bool
+	// DebugErrors - if true, details of 5xx errors responded by the server (e.g. panics) are exposed to clients
+	DebugErrors bool
 }
 
 // Server represents HTTP server
@@ -33,6 +64,9 @@ type Server struct {
 	NoAuthRouter *mux.Router         // NoAuthRouter - router not requiring authentication
 	WsUpgrader   *websocket.Upgrader // WsUpgrader - websocket upgrader
 	logger       log.CLoggerFunc     // logger
+	config       *Config             // config
+	tls          *utils.TlsReloader  // tls - reloads certificates if TLS is enabled
+	tlsErr       error               // tlsErr - error of loading certificates, server doesn't start if set
 }
 
 type RouteSetter interface {
@@ -51,9 +85,35 @@ type WsUpgrader interface {
 	Set(noAuthRouter *mux.Router, upgrader *websocket.Upgrader)
 }
 
+// NewHttpServer creates a new HTTP server
+// if TLS is enabled, certificates are loaded at once. If they cannot be loaded, Listen logs the error and the server doesn't start
 func NewHttpServer(corsOptions *Config, logger log.CLoggerFunc) *Server {
+	s, _ := newHttpServer(corsOptions, logger)
+	return s
+}
+
+// NewHttpServerTls creates a new HTTP server, error is returned if TLS is enabled and certificates cannot be loaded
+func NewHttpServerTls(corsOptions *Config, logger log.CLoggerFunc) (*Server, error) {
+	s, err := newHttpServer(corsOptions, logger)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newHttpServer(corsOptions *Config, logger log.CLoggerFunc) (*Server, error) {
 
 	r := mux.NewRouter()
+
+	// metrics endpoint is added before subrouters as they match any request
+	if corsOptions.Metrics {
+		path := corsOptions.MetricsPath
+		if path == "" {
+			path = metrics.DefaultPath
+		}
+		r.Handle(path, metrics.Handler()).Methods(http.MethodGet)
+	}
+
 	noAuthRouter := r.MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
 		return true
 	}).Subrouter()
@@ -63,14 +123,7 @@ func NewHttpServer(corsOptions *Config, logger log.CLoggerFunc) *Server {
 
 	s := &Server{
 		Srv: &http.Server{
-			Addr: fmt.Sprintf(":%s", corsOptions.Port),
-			Handler: cors.New(cors.Options{
-				AllowedOrigins:   corsOptions.AllowedOrigins,
-				AllowedMethods:   corsOptions.AllowedMethods,
-				AllowedHeaders:   corsOptions.AllowedHeaders,
-				AllowCredentials: true,
-				Debug:            corsOptions.Debug,
-			}).Handler(r),
+			Addr:         fmt.Sprintf(":%s", corsOptions.Port),
 			WriteTimeout: WriteTimeout,
 			ReadTimeout:  ReadTimeout,
 		},
@@ -85,9 +138,45 @@ func NewHttpServer(corsOptions *Config, logger log.CLoggerFunc) *Server {
 		AuthRouter:   authRouter,
 		NoAuthRouter: noAuthRouter,
 		logger:       logger,
+		config:       corsOptions,
 	}
 
-	return s
+	if corsOptions.TlsCertFile != "" {
+		tls, err := s.tlsReloader()
+		if err != nil {
+			s.tlsErr = ErrHttpSrvTls(err)
+		} else {
+			s.tls = tls
+			s.Srv.TLSConfig = tls.Config()
+		}
+	}
+
+	var handler http.Handler = r
+	if corsOptions.Tenant != nil {
+		c := &BaseController{Debug: corsOptions.DebugErrors}
+		handler = TenantMiddleware(c, corsOptions.Tenant)(handler)
+		noAuthRouter.Use(PublicTenantMiddleware(c, corsOptions.Tenant.Public))
+	}
+	if corsOptions.AccessLog || corsOptions.Recovery || corsOptions.Metrics || corsOptions.Tracing {
+		r.Use(routeMiddleware)
+		handler = s.instrumentHandler(handler)
+	}
+	var exposedHeaders []string
+	if !corsOptions.DisableRequestContext {
+		handler = RequestContextMiddleware(corsOptions.RequestContext)(handler)
+		exposedHeaders = append(exposedHeaders, corsOptions.RequestContext.withDefaults().RequestIdHeader)
+	}
+
+	s.Srv.Handler = cors.New(cors.Options{
+		AllowedOrigins:   corsOptions.AllowedOrigins,
+		AllowedMethods:   corsOptions.AllowedMethods,
+		AllowedHeaders:   corsOptions.AllowedHeaders,
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: true,
+		Debug:            corsOptions.Debug,
+	}).Handler(peerIdentityHandler(handler))
+
+	return s, s.tlsErr
 }
 
 func (s *Server) SetRouters(routeSetters ...RouteSetter) {
@@ -122,10 +211,20 @@ func (s *Server) SetMiddleware(mdws ...mux.MiddlewareFunc) {
 func (s *Server) Listen() {
 	go func() {
 		l := s.logger().Pr("http").Cmp("server").Mth("listen").F(log.FF{"url": s.Srv.Addr})
+		if s.tlsErr != nil {
+			l.E(s.tlsErr).St().Err()
+			return
+		}
 		l.Inf("start listening")
 
 		// if tls parameters are specified, list tls connection
-		if err := s.Srv.ListenAndServe(); err != nil {
+		var err error
+		if s.tls != nil {
+			err = s.Srv.ListenAndServeTLS("", "")
+		} else {
+			err = s.Srv.ListenAndServe()
+		}
+		if err != nil {
 			if err != http.ErrServerClosed {
 				l.E(ErrHttpSrvListen(err)).St().Err()
 			} else {
@@ -138,4 +237,35 @@ func (s *Server) Listen() {
 
 func (s *Server) Close() {
 	_ = s.Srv.Close()
+	if s.tls != nil {
+		s.tls.Close()
+	}
+}
+
+func (s *Server) tlsReloader() (*utils.TlsReloader, error) {
+	return utils.NewTlsReloader(&utils.TlsServerOptions{
+		CertFile:       s.config.TlsCertFile,
+		KeyFile:        s.config.TlsKeyFile,
+		CaFile:         s.config.TlsCaFile,
+		ClientAuth:     s.config.TlsClientAuth,
+		ReloadInterval: s.config.TlsReloadInterval,
+		OnReload: func(err error) {
+			l := s.logger().Pr("http").Cmp("server").Mth("tls-reload")
+			if err != nil {
+				l.E(ErrHttpSrvTls(err)).Err()
+			} else {
+				l.Inf("certificates reloaded")
+			}
+		},
+	})
+}
+
+// peerIdentityHandler puts identity of the client verified by mTLS to the request context
+func peerIdentityHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := kitContext.PeerIdentityFromTls(r.TLS); ok {
+			r = r.WithContext(id.ToContext(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
 }
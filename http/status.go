@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"google.golang.org/grpc/codes"
+)
+
+// grpcToHttpStatus maps gRPC codes to HTTP statuses
+var grpcToHttpStatus = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// HttpStatusFromGrpc maps gRPC code to HTTP status
+func HttpStatusFromGrpc(code codes.Code) int {
+	if st, ok := grpcToHttpStatus[code]; ok {
+		return st
+	}
+	return http.StatusInternalServerError
+}
+
+// HttpStatus returns HTTP status of the error
+// attached HTTP status takes precedence, then it's mapped from the attached gRPC status
+// aggregate error without status takes status of the first aggregated error, otherwise 500
+func HttpStatus(err error) int {
+	if appErr, ok := er.Is(err); ok {
+		if st := appErr.HttpStatus(); st != nil {
+			return int(*st)
+		}
+		if st := appErr.GrpcStatus(); st != nil {
+			return HttpStatusFromGrpc(codes.Code(*st))
+		}
+		if errs := appErr.Errors(); len(errs) > 0 {
+			return HttpStatus(errs[0])
+		}
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"google.golang.org/grpc/codes"
+)
+
+func Test_HttpStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"http status", er.WithBuilder("TST-001", "not found").HttpSt(http.StatusNotFound).GrpcSt(uint32(codes.Internal)).Err(), http.StatusNotFound},
+		{"grpc status", er.WithBuilder("TST-001", "denied").GrpcSt(uint32(codes.PermissionDenied)).Err(), http.StatusForbidden},
+		{"unknown grpc status", er.WithBuilder("TST-001", "test").GrpcSt(100).Err(), http.StatusInternalServerError},
+		{"wrapped", fmt.Errorf("wrapped: %w", er.WithBuilder("TST-001", "test").HttpSt(http.StatusConflict).Err()), http.StatusConflict},
+		{"aggregate", er.Aggregate([]error{er.WithBuilder("TST-001", "test").HttpSt(http.StatusBadRequest).Err()}, "TST-100", "failed").Err(), http.StatusBadRequest},
+		{"catalog", ErrHttpUrlVar(context.Background(), "id"), http.StatusBadRequest},
+		{"no status", er.New("TST-001", "test"), http.StatusInternalServerError},
+		{"not app error", errors.New("test"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if st := HttpStatus(tt.err); st != tt.status {
+				t.Fatalf("%d expected, got %d", tt.status, st)
+			}
+		})
+	}
+}
+
+func Test_RespondErrorStatus(t *testing.T) {
+	rs := httptest.NewRecorder()
+	(&BaseController{}).RespondError(rs, er.WithBuilder("TST-001", "denied").GrpcSt(uint32(codes.Unauthenticated)).Err())
+	if rs.Code != http.StatusUnauthorized {
+		t.Fatalf("401 expected, got %d", rs.Code)
+	}
+}
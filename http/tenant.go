@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"github.com/gorilla/mux"
+)
+
+const HeaderTenantId = "X-Tenant-Id"
+
+// TenantConfig specifies how tenant id is resolved from requests
+// if both header and subdomain are specified, they must be equal
+// tenant id is supplied by the client, so it's verified on authentication (see auth.Verifier):
+// token must carry the same tenant claim or membership must be confirmed, otherwise the request is rejected
+// routes not requiring authentication (NoAuthRouter) aren't verified, so requests with tenant are rejected there unless Public accepts them
+type TenantConfig struct {
+	// Header - tenant id header, X-Tenant-Id by default
+	Header string `config:"header"`
+	// Domain - base domain, tenant id is taken from its subdomain (e.g. "example.com" for "acme.example.com"), not used if empty
+	Domain string `config:"domain"`
+	// Public - validates tenant requested on routes not requiring authentication (e.g. checks the tenant exists and is public)
+	Public TenantValidateFunc `config:"-"`
+}
+
+// TenantValidateFunc validates tenant requested by the client, error denies access
+type TenantValidateFunc func(ctx context.Context, tenantId string) error
+
+// TenantMiddleware puts tenant id resolved from the request to the request context
+// invalid or conflicting tenant ids are responded with 400
+func TenantMiddleware(c *BaseController, cfg *TenantConfig) func(http.Handler) http.Handler {
+	if c == nil {
+		c = &BaseController{}
+	}
+	header := HeaderTenantId
+	var domain string
+	if cfg != nil {
+		if cfg.Header != "" {
+			header = cfg.Header
+		}
+		if cfg.Domain != "" {
+			domain = "." + strings.ToLower(strings.TrimPrefix(cfg.Domain, "."))
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			tenantId := r.Header.Get(header)
+			if domain != "" {
+				if sub := subdomain(r.Host, domain); sub != "" {
+					if tenantId != "" && tenantId != sub {
+						c.RespondError(w, ErrHttpTenantMismatch(r.Context(), tenantId, sub))
+						return
+					}
+					tenantId = sub
+				}
+			}
+
+			if tenantId != "" {
+				if !kitContext.IsValidTenantId(tenantId) {
+					c.RespondError(w, ErrHttpTenantInvalid(r.Context()))
+					return
+				}
+				rCtx, ok := kitContext.Request(r.Context())
+				if !ok {
+					rCtx = kitContext.NewRequestCtx().Rest().WithNewRequestId()
+				}
+				r = r.WithContext(rCtx.WithTenant(tenantId).ToContext(r.Context()))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PublicTenantMiddleware rejects requests with tenant unless validate accepts it (all are rejected if validate is nil)
+// it's supposed for routes not requiring authentication, where tenant isn't verified by token. The server applies it to NoAuthRouter if tenant is configured
+func PublicTenantMiddleware(c *BaseController, validate TenantValidateFunc) mux.MiddlewareFunc {
+	if c == nil {
+		c = &BaseController{}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tenantId, ok := kitContext.Tenant(r.Context()); ok && tenantId != "" {
+				var err error
+				if validate != nil {
+					err = validate(r.Context(), tenantId)
+				}
+				if validate == nil || err != nil {
+					c.RespondError(w, ErrHttpTenantNotAllowed(err, r.Context(), tenantId))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// subdomain returns the first label of the host if it's a direct subdomain of the domain
+func subdomain(host, domain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, domain) {
+		return ""
+	}
+	sub := strings.TrimSuffix(host, domain)
+	if strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
@@ -6,6 +6,7 @@ import (
 	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
 	"git.jetbrains.space/orbi/fcsd/kit/er"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 	"os"
 	"runtime"
 )
@@ -177,8 +178,21 @@ func (cl *clogger) C(ctx context.Context) CLogger {
 		if sid := r.GetSessionId(); sid != "" {
 			ff["ctx.sid"] = sid
 		}
+		if tid := r.GetTenantId(); tid != "" {
+			ff["ctx.tid"] = tid
+		}
+		if rrid := r.GetRootRequestId(); rrid != r.GetRequestId() {
+			ff["ctx.rrid"] = rrid
+		}
+		for k, v := range r.LogBaggage() {
+			ff["ctx.bg."+k] = v
+		}
 		cl.F(ff)
 	}
+	// trace and span ids correlate log entries with traces
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		cl.F(FF{"trace-id": sc.TraceID().String(), "span-id": sc.SpanID().String()})
+	}
 	return cl
 }
 
@@ -203,6 +217,19 @@ func (cl *clogger) E(err error) CLogger {
 			cl.lre = cl.lre.WithField(k, v)
 		}
 
+		// aggregated errors are logged as a list of code / message
+		if errs := appErr.Errors(); len(errs) > 0 {
+			var ee []map[string]interface{}
+			for _, e := range errs {
+				if a, ok := er.Is(e); ok {
+					ee = append(ee, map[string]interface{}{"code": a.Code(), "message": a.Message()})
+				} else {
+					ee = append(ee, map[string]interface{}{"message": e.Error()})
+				}
+			}
+			cl.lre = cl.lre.WithField("errors", ee)
+		}
+
 	} else {
 		cl.lre = cl.lre.WithError(err)
 	}
@@ -0,0 +1,85 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// kit metrics, they are collected by kit components if enabled in their configs
+
+var (
+	// HttpRequests - HTTP requests by method, route template and status
+	HttpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests",
+	}, []string{"method", "route", "status"})
+	// HttpRequestDuration - HTTP requests duration by method and route template
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// GrpcServerHandled - gRPC calls handled by server by method and code
+	GrpcServerHandled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Number of gRPC calls handled by server",
+	}, []string{"method", "code"})
+	// GrpcServerHandlingSeconds - duration of gRPC calls handled by server
+	GrpcServerHandlingSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Duration of gRPC calls handled by server",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	// GrpcClientHandled - gRPC calls made by client by method and code
+	GrpcClientHandled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_client_handled_total",
+		Help: "Number of gRPC calls made by client",
+	}, []string{"method", "code"})
+	// GrpcClientHandlingSeconds - duration of gRPC calls made by client
+	GrpcClientHandlingSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_client_handling_seconds",
+		Help:    "Duration of gRPC calls made by client",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// QueuePublished - published messages by topic (without tenant), queue type and status
+	QueuePublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_published_total",
+		Help: "Number of messages published to queue",
+	}, []string{"topic", "type", "status"})
+	// QueueConsumed - messages received from queue by topic (without tenant) and queue type
+	QueueConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_consumed_total",
+		Help: "Number of messages received from queue",
+	}, []string{"topic", "type"})
+	// QueueHandlingSeconds - duration of message handlers by topic (without tenant) and status
+	QueueHandlingSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "queue_handling_seconds",
+		Help:    "Duration of queue message handlers",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic", "status"})
+
+	// DbQueryDuration - duration of gorm queries by operation and table
+	DbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Duration of database queries",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "table", "status"})
+
+	// RedisCommandDuration - duration of Redis commands by command and status
+	RedisCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "Duration of Redis commands",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"command", "status"})
+
+	// RaftLeader - 1 if the node is the cluster leader
+	RaftLeader = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "raft_leader",
+		Help: "1 if the node is the cluster leader, 0 otherwise",
+	}, []string{"cluster"})
+
+	// Errors - errors by AppError code and source (http, grpc, queue)
+	Errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "errors_total",
+		Help: "Number of errors by code",
+	}, []string{"code", "source"})
+)
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	DefaultPath = "/metrics"
+
+	StatusOk    = "ok"
+	StatusError = "error"
+
+	SourceHttp  = "http"
+	SourceGrpc  = "grpc"
+	SourceQueue = "queue"
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	registry.MustRegister(
+		HttpRequests, HttpRequestDuration,
+		GrpcServerHandled, GrpcServerHandlingSeconds, GrpcClientHandled, GrpcClientHandlingSeconds,
+		QueuePublished, QueueConsumed, QueueHandlingSeconds,
+		DbQueryDuration,
+		RedisCommandDuration,
+		RaftLeader,
+		Errors,
+	)
+}
+
+// Registry returns registry containing all kit metrics, services register their own metrics here as well
+func Registry() *prometheus.Registry {
+	return registry
+}
+
+// Register registers collector in the kit registry
+// if the same collector is already registered, the existing one is returned
+func Register(c prometheus.Collector) prometheus.Collector {
+	if err := registry.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+// Handler exposes metrics of the kit registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Status returns status label value
+func Status(err error) string {
+	if err != nil {
+		return StatusError
+	}
+	return StatusOk
+}
+
+// Error counts error by code, errors which aren't AppError are counted with empty code
+func Error(err error, source string) {
+	if err == nil {
+		return
+	}
+	var code string
+	if appErr, ok := er.Is(err); ok {
+		code = appErr.Code()
+	}
+	Errors.WithLabelValues(code, source).Inc()
+}
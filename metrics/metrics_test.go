@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_Error(t *testing.T) {
+	Error(er.WithBuilder("TST-001", "test").Err(), SourceHttp)
+	Error(errors.New("test"), SourceHttp)
+	Error(nil, SourceHttp)
+
+	if v := testutil.ToFloat64(Errors.WithLabelValues("TST-001", SourceHttp)); v != 1 {
+		t.Fatalf("unexpected count %v", v)
+	}
+	if v := testutil.ToFloat64(Errors.WithLabelValues("", SourceHttp)); v != 1 {
+		t.Fatalf("unexpected count %v", v)
+	}
+}
+
+func Test_Register(t *testing.T) {
+	opts := prometheus.CounterOpts{Name: "test_total", Help: "test"}
+	c := Register(prometheus.NewCounter(opts))
+	if Register(prometheus.NewCounter(opts)) != c {
+		t.Fatal("existing collector expected")
+	}
+}
@@ -1,13 +1,34 @@
 package queue
 
-import "git.jetbrains.space/orbi/fcsd/kit/er"
+import (
+	"context"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+)
 
 const (
 	ErrCodeQueueMsgUnmarshal        = "QUE-001"
 	ErrCodeQueueMsgUnmarshalPayload = "QUE-002"
+	ErrCodeQueueNoTenant            = "QUE-003"
+	ErrCodeQueueTenantMismatch      = "QUE-004"
 )
 
 var (
-	ErrQueueMsgUnmarshal        = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeQueueMsgUnmarshal, "").Err() }
-	ErrQueueMsgUnmarshalPayload = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeQueueMsgUnmarshalPayload, "").Err() }
+	ErrQueueMsgUnmarshal        = func(cause error) error { return er.WrapWithCode(cause, ErrCodeQueueMsgUnmarshal).Err() }
+	ErrQueueMsgUnmarshalPayload = func(cause error) error { return er.WrapWithCode(cause, ErrCodeQueueMsgUnmarshalPayload).Err() }
+	ErrQueueNoTenant            = func(ctx context.Context, topic string) error {
+		return er.WithCode(ErrCodeQueueNoTenant).F(er.FF{"topic": topic}).C(ctx).Err()
+	}
+	ErrQueueTenantMismatch = func(ctx context.Context, topicTenant, msgTenant string) error {
+		return er.WithCode(ErrCodeQueueTenantMismatch).F(er.FF{"topic-tenant": topicTenant, "msg-tenant": msgTenant}).C(ctx).Err()
+	}
 )
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeQueueMsgUnmarshal, Message: "unmarshal queue message"},
+		&er.CodeDef{Code: ErrCodeQueueMsgUnmarshalPayload, Message: "unmarshal queue message payload"},
+		&er.CodeDef{Code: ErrCodeQueueNoTenant, Message: "tenant isn't specified"},
+		&er.CodeDef{Code: ErrCodeQueueTenantMismatch, Message: "message of another tenant rejected", Severity: er.SeverityWarning},
+	)
+}
@@ -2,8 +2,10 @@ package listener
 
 import (
 	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
 	"git.jetbrains.space/orbi/fcsd/kit/queue"
 	"sync"
+	"time"
 )
 
 // QueueMessageHandler is a func which acts as message handler
@@ -29,7 +31,21 @@ type topicKey struct {
 	LbGroup string // LB group
 }
 
+// QueueListenerOptions allows customizing listener
+type QueueListenerOptions struct {
+	// Metrics - if true, handling duration and errors are collected by topic (without tenant, see queue.BaseTopic)
+	Metrics bool
+}
+
 func NewQueueListener(q queue.Queue, logger log.CLoggerFunc) QueueListener {
+	return NewQueueListenerWithOptions(q, logger, &QueueListenerOptions{})
+}
+
+// NewQueueListenerWithOptions creates listener with custom options
+func NewQueueListenerWithOptions(q queue.Queue, logger log.CLoggerFunc, opts *QueueListenerOptions) QueueListener {
+	if opts == nil {
+		opts = &QueueListenerOptions{}
+	}
 
 	th := map[queue.QueueType]map[topicKey][]QueueMessageHandler{}
 	th[queue.QueueTypeAtLeastOnce] = make(map[topicKey][]QueueMessageHandler)
@@ -40,6 +56,7 @@ func NewQueueListener(q queue.Queue, logger log.CLoggerFunc) QueueListener {
 		listening:     false,
 		queue:         q,
 		logger:        logger,
+		opts:          opts,
 	}
 }
 
@@ -50,6 +67,7 @@ type queueListener struct {
 	quit          chan struct{}
 	listening     bool
 	logger        log.CLoggerFunc
+	opts          *QueueListenerOptions
 }
 
 func (q *queueListener) add(qt queue.QueueType, topic, lbGroup string, h ...QueueMessageHandler) {
@@ -108,7 +126,15 @@ func (q *queueListener) ListenAsync() {
 							// execute handlers within a separate goroutines
 							go func() {
 								l := q.logger().Pr("queue").Cmp("listener").F(log.FF{"topic": tp}).TrcF("%s", string(m))
-								if err := h(m); err != nil {
+								start := time.Now()
+								err := h(m)
+								if q.opts.Metrics {
+									metrics.QueueHandlingSeconds.WithLabelValues(queue.BaseTopic(tp), metrics.Status(err)).Observe(time.Since(start).Seconds())
+									if err != nil {
+										metrics.Error(err, metrics.SourceQueue)
+									}
+								}
+								if err != nil {
 									l.E(err).St().Err()
 								}
 							}()
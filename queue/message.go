@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	kitCtx "git.jetbrains.space/orbi/fcsd/kit/context"
+	"git.jetbrains.space/orbi/fcsd/kit/tracing"
 )
 
 type Message struct {
 	Ctx     *kitCtx.RequestContext `json:"ctx"`
 	Payload interface{}            `json:"pl"`
+	// Headers - transport headers (e.g. W3C trace context), so that consumers continue the trace
+	Headers map[string]string `json:"hdrs,omitempty"`
 }
 
 func Decode(parentCtx context.Context, msg []byte, payload interface{}) (context.Context, error) {
@@ -37,7 +40,13 @@ func Decode(parentCtx context.Context, msg []byte, payload interface{}) (context
 		parentCtx = context.Background()
 	}
 
+	if m.Ctx != nil {
+		m.Ctx = m.Ctx.FromSink(kitCtx.BaggageSinkQueue)
+	}
 	ctx := m.Ctx.ToContext(parentCtx)
+	if len(m.Headers) > 0 {
+		ctx = tracing.Extract(ctx, tracing.MapCarrier(m.Headers))
+	}
 
 	return ctx, nil
 
@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	kitCtx "git.jetbrains.space/orbi/fcsd/kit/context"
+)
+
+// Test_ConcurrentDecode must be run with -race
+func Test_ConcurrentDecode(t *testing.T) {
+	rCtx := kitCtx.NewRequestCtx().Queue().WithNewRequestId()
+	data, _ := json.Marshal(&Message{Ctx: rCtx, Payload: map[string]interface{}{"k": "v"}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, err := Decode(context.Background(), data, map[string]interface{}{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			r, _ := kitCtx.Request(ctx)
+			if r.GetRequestId() != rCtx.GetRequestId() {
+				t.Error("request context isn't propagated")
+			}
+			// handlers derive their own contexts
+			child, _ := kitCtx.Request(kitCtx.WithChild(ctx))
+			if child.GetParentRequestId() != rCtx.GetRequestId() {
+				t.Error("child context expected")
+			}
+			// republishing shares the decoded request context
+			_, _ = json.Marshal(&Message{Ctx: r.ForSink(kitCtx.BaggageSinkQueue)})
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_Tenant(t *testing.T) {
+	ctxA := kitCtx.NewRequestCtx().Queue().WithNewRequestId().WithTenant("a").ToContext(context.Background())
+
+	topic, err := TenantTopic(ctxA, "orders")
+	if err != nil || topic != "orders.tenant.a" {
+		t.Fatalf("unexpected topic %s %v", topic, err)
+	}
+	if base := BaseTopic(topic); base != "orders" {
+		t.Fatalf("unexpected base topic %s", base)
+	}
+	if base := BaseTopic("orders.created"); base != "orders.created" {
+		t.Fatalf("unexpected base topic %s", base)
+	}
+	if _, err := TenantTopic(context.Background(), "orders"); err == nil {
+		t.Fatal("topic without tenant mustn't be built")
+	}
+
+	rCtx, _ := kitCtx.Request(ctxA)
+	data, _ := json.Marshal(&Message{Ctx: rCtx, Payload: map[string]interface{}{}})
+
+	if ctx, err := DecodeTenant(context.Background(), "a", data, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	} else if tid, _ := kitCtx.Tenant(ctx); tid != "a" {
+		t.Fatalf("unexpected tenant %s", tid)
+	}
+
+	// message of tenant "a" received from topic of tenant "b"
+	if _, err := DecodeTenant(context.Background(), "b", data, map[string]interface{}{}); err == nil {
+		t.Fatal("cross tenant message must be rejected")
+	}
+
+	// message without tenant gets tenant of the topic
+	data, _ = json.Marshal(&Message{Ctx: kitCtx.NewRequestCtx().Queue().WithNewRequestId(), Payload: map[string]interface{}{}})
+	if ctx, err := DecodeTenant(context.Background(), "b", data, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	} else if tid, _ := kitCtx.Tenant(ctx); tid != "b" {
+		t.Fatalf("unexpected tenant %s", tid)
+	}
+}
@@ -26,6 +26,8 @@ type Config struct {
 	Host      string
 	Port      string
 	ClusterId string
+	// Metrics - if true, published and consumed messages are counted by topic (without tenant, see BaseTopic) and queue type
+	Metrics bool
 }
 
 // Queue allows async communication with a message queue
@@ -14,14 +14,27 @@ const (
 )
 
 var (
-	ErrStanNoOpenConn     = func() error { return er.WithBuilder(ErrCodeStanNoOpenConn, "no open connections").Err() }
+	ErrStanNoOpenConn     = func() error { return er.WithCode(ErrCodeStanNoOpenConn).Err() }
 	ErrStanQtNotSupported = func(qt int) error {
-		return er.WithBuilder(ErrCodeStanQtNotSupported, "queue type not supported").F(er.FF{"qt": qt}).Err()
+		return er.WithCode(ErrCodeStanQtNotSupported).F(er.FF{"qt": qt}).Err()
 	}
-	ErrStanConnect              = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeStanConnect, "").Err() }
-	ErrStanClose                = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeStanClose, "").Err() }
-	ErrStanPublishAtLeastOnce   = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeStanPublishAtLeastOnce, "").Err() }
-	ErrStanPublishAtMostOnce    = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeStanPublishAtMostOnce, "").Err() }
-	ErrStanSubscribeAtLeastOnce = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeStanSubscribeAtLeastOnce, "").Err() }
-	ErrStanSubscribeAtMostOnce  = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeStanSubscribeAtMostOnce, "").Err() }
+	ErrStanConnect              = func(cause error) error { return er.WrapWithCode(cause, ErrCodeStanConnect).Err() }
+	ErrStanClose                = func(cause error) error { return er.WrapWithCode(cause, ErrCodeStanClose).Err() }
+	ErrStanPublishAtLeastOnce   = func(cause error) error { return er.WrapWithCode(cause, ErrCodeStanPublishAtLeastOnce).Err() }
+	ErrStanPublishAtMostOnce    = func(cause error) error { return er.WrapWithCode(cause, ErrCodeStanPublishAtMostOnce).Err() }
+	ErrStanSubscribeAtLeastOnce = func(cause error) error { return er.WrapWithCode(cause, ErrCodeStanSubscribeAtLeastOnce).Err() }
+	ErrStanSubscribeAtMostOnce  = func(cause error) error { return er.WrapWithCode(cause, ErrCodeStanSubscribeAtMostOnce).Err() }
 )
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeStanNoOpenConn, Message: "no open connections"},
+		&er.CodeDef{Code: ErrCodeStanQtNotSupported, Message: "queue type not supported"},
+		&er.CodeDef{Code: ErrCodeStanConnect, Message: "stan connect failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeStanClose, Message: "stan close failed", Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeStanPublishAtLeastOnce, Message: "publish at-least-once failed"},
+		&er.CodeDef{Code: ErrCodeStanPublishAtMostOnce, Message: "publish at-most-once failed"},
+		&er.CodeDef{Code: ErrCodeStanSubscribeAtLeastOnce, Message: "subscribe at-least-once failed"},
+		&er.CodeDef{Code: ErrCodeStanSubscribeAtMostOnce, Message: "subscribe at-most-once failed"},
+	)
+}
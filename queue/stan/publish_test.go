@@ -0,0 +1,98 @@
+package stan
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
+	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
+	"git.jetbrains.space/orbi/fcsd/kit/queue"
+	"github.com/nats-io/stan.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// connMock captures published messages
+type connMock struct {
+	stan.Conn
+	sync.Mutex
+	published [][]byte
+}
+
+func (c *connMock) Publish(subject string, data []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	c.published = append(c.published, data)
+	return nil
+}
+
+func Test_PublishNilCtx(t *testing.T) {
+	logger := log.Init(&log.Config{Level: log.ErrorLevel})
+	conn := &connMock{}
+	s := &stanImpl{conn: conn, logger: func() log.CLogger { return log.L(logger) }}
+
+	if err := s.Publish(context.Background(), queue.QueueTypeAtLeastOnce, "topic", &queue.Message{Payload: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.published) != 1 {
+		t.Fatalf("unexpected published messages %d", len(conn.published))
+	}
+	var m queue.Message
+	if err := json.Unmarshal(conn.published[0], &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Ctx == nil || m.Ctx.GetRequestId() == "" || m.Ctx.GetClientType() != kitContext.CLIENT_TYPE_QUEUE {
+		t.Fatalf("request context expected, got %+v", m.Ctx)
+	}
+}
+
+func Test_PublishSharedMessage(t *testing.T) {
+	logger := log.Init(&log.Config{Level: log.ErrorLevel})
+	conn := &connMock{}
+	s := &stanImpl{conn: conn, logger: func() log.CLogger { return log.L(logger) }}
+
+	// the same message is published concurrently, it mustn't be changed
+	msg := &queue.Message{Payload: "test"}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Publish(context.Background(), queue.QueueTypeAtLeastOnce, "topic", msg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if msg.Headers != nil || msg.Ctx != nil {
+		t.Fatalf("message mustn't be changed, got %+v", msg)
+	}
+	if len(conn.published) != 10 {
+		t.Fatalf("unexpected published messages %d", len(conn.published))
+	}
+}
+
+func Test_PublishMetrics(t *testing.T) {
+	logger := log.Init(&log.Config{Level: log.ErrorLevel})
+	s := &stanImpl{conn: &connMock{}, logger: func() log.CLogger { return log.L(logger) }}
+	ctx := kitContext.NewRequestCtx().WithNewRequestId().WithTenant("a").ToContext(context.Background())
+	topic, _ := queue.TenantTopic(ctx, "metrics")
+	published := metrics.QueuePublished.WithLabelValues("metrics", queue.QueueType(queue.QueueTypeAtLeastOnce).String(), metrics.Status(nil))
+
+	if err := s.Publish(ctx, queue.QueueTypeAtLeastOnce, topic, &queue.Message{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(published); v != 0 {
+		t.Fatalf("metrics are disabled, got %v", v)
+	}
+
+	s.metrics = true
+	if err := s.Publish(ctx, queue.QueueTypeAtLeastOnce, topic, &queue.Message{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(published); v != 1 {
+		t.Fatalf("message must be counted by base topic, got %v", v)
+	}
+}
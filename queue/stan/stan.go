@@ -6,15 +6,20 @@ import (
 	"fmt"
 	kitContext "git.jetbrains.space/orbi/fcsd/kit/context"
 	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
 	"git.jetbrains.space/orbi/fcsd/kit/queue"
+	"git.jetbrains.space/orbi/fcsd/kit/tracing"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/stan.go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type stanImpl struct {
 	conn     stan.Conn
 	clientId string
 	logger   log.CLoggerFunc
+	metrics  bool
 }
 
 func New(logger log.CLoggerFunc) queue.Queue {
@@ -27,11 +32,20 @@ func (s *stanImpl) l() log.CLogger {
 	return s.logger().Pr("queue").Cmp("stan")
 }
 
+// consumed returns func counting consumed messages if metrics are enabled
+func (s *stanImpl) consumed(qt queue.QueueType, topic string) func() {
+	if !s.metrics {
+		return func() {}
+	}
+	return metrics.QueueConsumed.WithLabelValues(queue.BaseTopic(topic), qt.String()).Inc
+}
+
 func (s *stanImpl) Open(ctx context.Context, clientId string, config *queue.Config) error {
 
 	l := s.l().Mth("open").F(log.FF{"client": clientId, "host": config.Host}).Dbg("connecting")
 
 	s.clientId = clientId
+	s.metrics = config.Metrics
 	url := fmt.Sprintf("nats://%s:%s", config.Host, config.Port)
 	c, err := stan.Connect(config.ClusterId, clientId, stan.NatsURL(url))
 	if err != nil {
@@ -58,6 +72,27 @@ func (s *stanImpl) Close() error {
 
 func (s *stanImpl) Publish(ctx context.Context, qt queue.QueueType, topic string, msg *queue.Message) error {
 
+	// trace context is passed in message headers
+	// message is copied, so that caller's message isn't changed and can be published concurrently
+	ctx, span := tracing.Start(ctx, "publish "+topic, trace.WithSpanKind(trace.SpanKindProducer))
+	pMsg := *msg
+	pMsg.Headers = make(map[string]string, len(msg.Headers))
+	for k, v := range msg.Headers {
+		pMsg.Headers[k] = v
+	}
+	tracing.Inject(ctx, tracing.MapCarrier(pMsg.Headers))
+
+	err := s.publish(ctx, qt, topic, &pMsg)
+
+	tracing.End(span, err, semconv.MessagingSystemKey.String("stan"), semconv.MessagingDestinationKey.String(topic))
+	if s.metrics {
+		metrics.QueuePublished.WithLabelValues(queue.BaseTopic(topic), qt.String(), metrics.Status(err)).Inc()
+	}
+	return err
+}
+
+func (s *stanImpl) publish(ctx context.Context, qt queue.QueueType, topic string, msg *queue.Message) error {
+
 	l := s.l().Mth("publish").F(log.FF{"topic": topic, "type": qt.String()})
 
 	if msg.Ctx == nil {
@@ -68,7 +103,10 @@ func (s *stanImpl) Publish(ctx context.Context, qt queue.QueueType, topic string
 		return ErrStanNoOpenConn()
 	}
 
-	m, err := json.Marshal(msg)
+	// only baggage allowed for queue is sent
+	qMsg := *msg
+	qMsg.Ctx = msg.Ctx.ForSink(kitContext.BaggageSinkQueue)
+	m, err := json.Marshal(&qMsg)
 	if err != nil {
 		return err
 	}
@@ -92,11 +130,13 @@ func (s *stanImpl) Publish(ctx context.Context, qt queue.QueueType, topic string
 func (s *stanImpl) Subscribe(qt queue.QueueType, topic string, receiverChan chan<- []byte) error {
 
 	l := s.l().Mth("received").F(log.FF{"topic": topic, "type": qt.String()})
+	consumed := s.consumed(qt, topic)
 
 	if qt == queue.QueueTypeAtLeastOnce {
 
 		_, err := s.conn.Subscribe(topic, func(m *stan.Msg) {
 			l.TrcF("%s\n", string(m.Data))
+			consumed()
 			receiverChan <- m.Data
 		}, stan.DurableName(s.clientId))
 		if err != nil {
@@ -107,6 +147,7 @@ func (s *stanImpl) Subscribe(qt queue.QueueType, topic string, receiverChan chan
 	} else if qt == queue.QueueTypeAtMostOnce {
 		_, err := s.conn.NatsConn().Subscribe(topic, func(m *nats.Msg) {
 			l.TrcF("%s\n", string(m.Data))
+			consumed()
 			receiverChan <- m.Data
 		})
 		if err != nil {
@@ -121,11 +162,13 @@ func (s *stanImpl) Subscribe(qt queue.QueueType, topic string, receiverChan chan
 
 func (s *stanImpl) SubscribeLB(qt queue.QueueType, topic, loadBalancingGroup string, receiverChan chan<- []byte) error {
 	l := s.l().Mth("received").F(log.FF{"topic": topic, "type": qt.String(), "lbGrp": loadBalancingGroup})
+	consumed := s.consumed(qt, topic)
 
 	if qt == queue.QueueTypeAtLeastOnce {
 
 		_, err := s.conn.QueueSubscribe(topic, loadBalancingGroup, func(m *stan.Msg) {
 			l.TrcF("%s\n", string(m.Data))
+			consumed()
 			receiverChan <- m.Data
 		}, stan.DurableName(s.clientId))
 		if err != nil {
@@ -136,6 +179,7 @@ func (s *stanImpl) SubscribeLB(qt queue.QueueType, topic, loadBalancingGroup str
 	} else if qt == queue.QueueTypeAtMostOnce {
 		_, err := s.conn.NatsConn().QueueSubscribe(topic, loadBalancingGroup, func(m *nats.Msg) {
 			l.TrcF("%s\n", string(m.Data))
+			consumed()
 			receiverChan <- m.Data
 		})
 		if err != nil {
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"context"
+	"strings"
+
+	kitCtx "git.jetbrains.space/orbi/fcsd/kit/context"
+)
+
+// tenantTopicSeparator separates topic and tenant in topics of tenants
+const tenantTopicSeparator = ".tenant."
+
+// TenantTopic returns topic of the request context tenant ("<topic>.tenant.<tenant>"), so that each tenant is served by its own subscriptions
+// it fails if there is no tenant
+func TenantTopic(ctx context.Context, topic string) (string, error) {
+	tenantId, ok := kitCtx.Tenant(ctx)
+	if !ok {
+		return "", ErrQueueNoTenant(ctx, topic)
+	}
+	return topic + tenantTopicSeparator + tenantId, nil
+}
+
+// BaseTopic returns topic without tenant (see TenantTopic), other topics are returned as is
+// it's used where topic of each tenant mustn't be distinguished (e.g. metrics labels)
+func BaseTopic(topic string) string {
+	if i := strings.LastIndex(topic, tenantTopicSeparator); i > 0 && kitCtx.IsValidTenantId(topic[i+len(tenantTopicSeparator):]) {
+		return topic[:i]
+	}
+	return topic
+}
+
+// DecodeTenant decodes message received from a topic of the tenant (see TenantTopic)
+// message of another tenant is rejected, message without tenant gets the tenant of the topic
+func DecodeTenant(parentCtx context.Context, tenantId string, msg []byte, payload interface{}) (context.Context, error) {
+	ctx, err := Decode(parentCtx, msg, payload)
+	if err != nil {
+		return nil, err
+	}
+	rCtx, ok := kitCtx.Request(ctx)
+	if !ok {
+		rCtx = kitCtx.NewRequestCtx().Queue().WithNewRequestId()
+	}
+	if rCtx.GetTenantId() == "" {
+		return rCtx.WithTenant(tenantId).ToContext(ctx), nil
+	}
+	if rCtx.GetTenantId() != tenantId {
+		return nil, ErrQueueTenantMismatch(ctx, tenantId, rCtx.GetTenantId())
+	}
+	return ctx, nil
+}
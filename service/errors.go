@@ -12,12 +12,23 @@ const (
 )
 
 var (
-	ErrRaftOddSize           = func() error { return er.WithBuilder(ErrCodeRaftOddSize, "cannot start cluster with odd size").Err() }
-	ErrNatsRpc               = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeNatsRpc, "").Err() }
-	ErrStart                 = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeStart, "").Err() }
+	ErrRaftOddSize           = func() error { return er.WithCode(ErrCodeRaftOddSize).Err() }
+	ErrNatsRpc               = func(cause error) error { return er.WrapWithCode(cause, ErrCodeNatsRpc).Err() }
+	ErrStart                 = func(cause error) error { return er.WrapWithCode(cause, ErrCodeStart).Err() }
 	ErrSvcClusterInitOddSize = func() error {
-		return er.WithBuilder(ErrCodeSvcClusterInitOddSize, "cannot start cluster with odd size").Err()
+		return er.WithCode(ErrCodeSvcClusterInitOddSize).Err()
 	}
-	ErrRaftInit  = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeRaftInit, "").Err() }
-	ErrRaftStart = func(cause error) error { return er.WrapWithBuilder(cause, ErrCodeRaftStart, "").Err() }
+	ErrRaftInit  = func(cause error) error { return er.WrapWithCode(cause, ErrCodeRaftInit).Err() }
+	ErrRaftStart = func(cause error) error { return er.WrapWithCode(cause, ErrCodeRaftStart).Err() }
 )
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeRaftOddSize, Message: "cannot start cluster with odd size", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeNatsRpc, Message: "nats rpc failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeStart, Message: "raft start failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeSvcClusterInitOddSize, Message: "cannot start cluster with odd size", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeRaftInit, Message: "raft init failed", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeRaftStart, Message: "raft start failed", Severity: er.SeverityCritical},
+	)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"git.jetbrains.space/orbi/fcsd/kit/log"
+	"git.jetbrains.space/orbi/fcsd/kit/metrics"
 	"go.uber.org/atomic"
 )
 
@@ -122,7 +123,7 @@ func (c *Cluster) Init(config *Config, natsHost, natsPort string, ev OnLeaderCha
 		NatsUrl:     natsUrl,
 		LogPath:     config.Log,
 	}, func(l bool) {
-		c.Meta.SetMeAsLeader(l)
+		c.setLeader(l)
 		if ev != nil {
 			ev(l)
 		}
@@ -139,6 +140,16 @@ func (c *Cluster) Init(config *Config, natsHost, natsPort string, ev OnLeaderCha
 
 }
 
+// setLeader updates leadership of the node and exposes it as raft_leader gauge
+func (c *Cluster) setLeader(l bool) {
+	c.Meta.SetMeAsLeader(l)
+	var v float64
+	if l {
+		v = 1
+	}
+	metrics.RaftLeader.WithLabelValues(c.Meta.ServiceCode()).Set(v)
+}
+
 // Start starts the cluster
 func (c *Cluster) Start() error {
 
@@ -149,7 +160,7 @@ func (c *Cluster) Start() error {
 	if err := c.Raft.Start(); err != nil {
 		return ErrRaftStart(err)
 	}
-	c.Meta.SetMeAsLeader(c.Raft.AmILeader())
+	c.setLeader(c.Raft.AmILeader())
 	c.logger().Cmp("cluster").Mth("start").Inf("ok")
 	return nil
 }
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataCarrier adapts gRPC metadata to propagation.TextMapCarrier
+type MetadataCarrier metadata.MD
+
+func (m MetadataCarrier) Get(key string) string {
+	if v := metadata.MD(m).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (m MetadataCarrier) Set(key, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+func (m MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// MapCarrier adapts map (e.g. queue message headers) to propagation.TextMapCarrier
+type MapCarrier map[string]string
+
+func (m MapCarrier) Get(key string) string {
+	return m[key]
+}
+
+func (m MapCarrier) Set(key, value string) {
+	m[key] = value
+}
+
+func (m MapCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
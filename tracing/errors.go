@@ -0,0 +1,24 @@
+package tracing
+
+import "git.jetbrains.space/orbi/fcsd/kit/er"
+
+const (
+	ErrCodeTracingExporter             = "TRC-001"
+	ErrCodeTracingExporterNotSupported = "TRC-002"
+)
+
+var (
+	ErrTracingExporter = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeTracingExporter).Err()
+	}
+	ErrTracingExporterNotSupported = func(exporter string) error {
+		return er.WithCode(ErrCodeTracingExporterNotSupported).F(er.FF{"exporter": exporter}).Err()
+	}
+)
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeTracingExporter, Message: "create tracing exporter", Severity: er.SeverityCritical},
+		&er.CodeDef{Code: ErrCodeTracingExporterNotSupported, Message: "tracing exporter isn't supported", Severity: er.SeverityCritical},
+	)
+}
@@ -0,0 +1,67 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"git.jetbrains.space/orbi/fcsd/kit/queue"
+	"git.jetbrains.space/orbi/fcsd/kit/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+func recorder(t *testing.T) *tracetest.SpanRecorder {
+	if _, err := tracing.Init(context.Background(), "test", &tracing.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	return rec
+}
+
+func Test_QueuePropagation(t *testing.T) {
+	recorder(t)
+
+	ctx, span := tracing.Start(context.Background(), "producer")
+	defer span.End()
+
+	msg := &queue.Message{Payload: map[string]interface{}{"k": "v"}, Headers: map[string]string{}}
+	tracing.Inject(ctx, tracing.MapCarrier(msg.Headers))
+	data, _ := json.Marshal(msg)
+
+	consumerCtx, err := queue.Decode(context.Background(), data, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := trace.SpanContextFromContext(consumerCtx)
+	if !sc.IsRemote() || sc.TraceID() != span.SpanContext().TraceID() {
+		t.Fatalf("trace isn't continued, got %v", sc.TraceID())
+	}
+}
+
+func Test_MetadataPropagation(t *testing.T) {
+	rec := recorder(t)
+
+	ctx, span := tracing.Start(context.Background(), "client")
+	md := metadata.MD{}
+	tracing.Inject(ctx, tracing.MetadataCarrier(md))
+	tracing.End(span, nil)
+
+	_, child := tracing.Start(tracing.Extract(context.Background(), tracing.MetadataCarrier(md)), "server")
+	tracing.End(child, nil)
+
+	ended := rec.Ended()
+	if len(ended) != 2 || ended[1].Parent().SpanID() != ended[0].SpanContext().SpanID() {
+		t.Fatal("server span must be a child of client span")
+	}
+}
+
+func Test_UnsupportedExporter(t *testing.T) {
+	if _, err := tracing.Init(context.Background(), "test", &tracing.Config{Exporter: "unknown"}); err == nil {
+		t.Fatal("error expected")
+	}
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadTlsConfig builds TLS config from PEM files
+// caFile - CA certificates used to verify the peer, system pool is used if empty
+// certFile, keyFile - own certificate and private key, skipped if empty
+func LoadTlsConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// LoadCertPool loads PEM encoded certificates from file to a new pool
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	ca, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+const (
+	TlsClientAuthNone     = ""         // TlsClientAuthNone - client certificate isn't requested
+	TlsClientAuthOptional = "optional" // TlsClientAuthOptional - client certificate is verified if given
+	TlsClientAuthRequire  = "require"  // TlsClientAuthRequire - client certificate is required and verified
+
+	DefaultTlsReloadInterval = time.Minute
+)
+
+// TlsServerOptions specifies server TLS
+type TlsServerOptions struct {
+	// CertFile, KeyFile - server certificate and private key
+	CertFile string
+	KeyFile  string
+	// CaFile - CA certificates used to verify clients, system pool is used if empty
+	CaFile string
+	// ClientAuth - client certificate verification (see TlsClientAuth* constants)
+	ClientAuth string
+	// ReloadInterval - how often files are checked for changes, DefaultTlsReloadInterval if empty, no reload if negative
+	ReloadInterval time.Duration
+	// OnReload - called after files are reloaded with error if reloading failed (the previous certificate is kept in this case)
+	OnReload func(err error)
+}
+
+// TlsReloader keeps server certificate and client CAs up to date with files (e.g. rotated by cert-manager)
+type TlsReloader struct {
+	sync.RWMutex
+	opts       *TlsServerOptions
+	clientAuth tls.ClientAuthType
+	cert       *tls.Certificate
+	pool       *x509.CertPool
+	stamp      string
+	quit       chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewTlsReloader loads certificates and starts watching files for changes
+func NewTlsReloader(opts *TlsServerOptions) (*TlsReloader, error) {
+
+	r := &TlsReloader{
+		opts: opts,
+		quit: make(chan struct{}),
+	}
+
+	switch opts.ClientAuth {
+	case TlsClientAuthNone:
+		r.clientAuth = tls.NoClientCert
+	case TlsClientAuthOptional:
+		r.clientAuth = tls.VerifyClientCertIfGiven
+	case TlsClientAuthRequire:
+		r.clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("client auth %s isn't supported", opts.ClientAuth)
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	interval := opts.ReloadInterval
+	if interval == 0 {
+		interval = DefaultTlsReloadInterval
+	}
+	if interval > 0 {
+		go r.watch(interval)
+	}
+
+	return r, nil
+}
+
+// Config returns TLS config, which always uses the latest loaded certificate and CAs
+func (r *TlsReloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.RLock()
+			defer r.RUnlock()
+			return r.cert, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.RLock()
+			defer r.RUnlock()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				ClientAuth:   r.clientAuth,
+				ClientCAs:    r.pool,
+				NextProtos:   []string{"h2", "http/1.1"},
+			}, nil
+		},
+	}
+}
+
+// Close stops watching files
+func (r *TlsReloader) Close() {
+	r.closeOnce.Do(func() { close(r.quit) })
+}
+
+func (r *TlsReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.quit:
+			return
+		case <-ticker.C:
+			stamp, err := r.filesStamp()
+			if err == nil {
+				r.RLock()
+				changed := stamp != r.stamp
+				r.RUnlock()
+				if !changed {
+					continue
+				}
+				err = r.load()
+			}
+			if r.opts.OnReload != nil {
+				r.opts.OnReload(err)
+			}
+		}
+	}
+}
+
+// load loads certificate and CAs
+func (r *TlsReloader) load() error {
+
+	stamp, err := r.filesStamp()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	var pool *x509.CertPool
+	if r.opts.CaFile != "" {
+		if pool, err = LoadCertPool(r.opts.CaFile); err != nil {
+			return err
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.cert, r.pool, r.stamp = &cert, pool, stamp
+
+	return nil
+}
+
+// filesStamp returns a string changing whenever any of files is modified
+func (r *TlsReloader) filesStamp() (string, error) {
+	var sb strings.Builder
+	for _, f := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.CaFile} {
+		if f == "" {
+			continue
+		}
+		fi, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(fmt.Sprintf("%s:%d:%d;", f, fi.ModTime().UnixNano(), fi.Size()))
+	}
+	return sb.String(), nil
+}
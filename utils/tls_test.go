@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCert(t *testing.T, dir, cn string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, cfg *tls.Config) string {
+	c, err := cfg.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(c.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func Test_TlsReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first")
+
+	r, err := NewTlsReloader(&TlsServerOptions{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: time.Millisecond * 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	cfg := r.Config()
+	if cn := commonName(t, cfg); cn != "first" {
+		t.Fatalf("unexpected certificate %s", cn)
+	}
+
+	// make sure modification time differs
+	time.Sleep(time.Millisecond * 20)
+	writeCert(t, dir, "second")
+
+	// cert and key are written one by one, so reloading may fail in between
+	deadline := time.Now().Add(time.Second)
+	for commonName(t, cfg) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate isn't reloaded")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func Test_TlsReloaderClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "test")
+	if _, err := NewTlsReloader(&TlsServerOptions{CertFile: certFile, KeyFile: keyFile, ClientAuth: "unknown"}); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func Test_LoadTlsConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "client")
+
+	cfg, err := LoadTlsConfig(certFile, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 || cfg.RootCAs == nil || len(cfg.Certificates) != 1 {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	// system pool is used and no certificate is presented if files aren't specified
+	if cfg, err := LoadTlsConfig("", "", ""); err != nil || cfg.RootCAs != nil || len(cfg.Certificates) != 0 {
+		t.Fatalf("unexpected config %+v %v", cfg, err)
+	}
+
+	if _, err := LoadTlsConfig(filepath.Join(dir, "none.crt"), "", ""); err == nil {
+		t.Fatal("missing CA file must fail")
+	}
+	if _, err := LoadTlsConfig("", certFile, certFile); err == nil {
+		t.Fatal("invalid key must fail")
+	}
+	if _, err := LoadCertPool(keyFile); err == nil {
+		t.Fatal("file without certificates must fail")
+	}
+}
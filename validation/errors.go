@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"context"
+	"net/http"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	ErrCodeValidation      = "VAL-001"
+	ErrCodeValidationField = "VAL-002"
+	ErrCodeValidationFunc  = "VAL-003"
+	ErrCodeDecodeRequest   = "VAL-004"
+)
+
+var (
+	ErrValidation = func(ctx context.Context, errs []error) error {
+		return er.AggregateWithCode(errs, ErrCodeValidation).C(ctx).Err()
+	}
+	ErrValidationField = func(field, tag, param, message string) error {
+		return er.WithCode(ErrCodeValidationField, message).F(er.FF{er.FieldKeyField: field, "tag": tag, "param": param}).Err()
+	}
+	ErrValidationFunc = func(cause error) error {
+		return er.WrapWithCode(cause, ErrCodeValidationFunc).Err()
+	}
+	ErrDecodeRequest = func(cause error, ctx context.Context) error {
+		return er.WrapWithCode(cause, ErrCodeDecodeRequest).C(ctx).Err()
+	}
+)
+
+func init() {
+	er.Register(
+		&er.CodeDef{Code: ErrCodeValidation, Message: "validation failed", HttpStatus: http.StatusBadRequest, GrpcStatus: er.GrpcCode(uint32(codes.InvalidArgument)), Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeValidationField, Message: "%s", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeValidationFunc, Message: "validation failed", HttpStatus: http.StatusBadRequest, Severity: er.SeverityWarning},
+		&er.CodeDef{Code: ErrCodeDecodeRequest, Message: "invalid request", HttpStatus: http.StatusBadRequest, GrpcStatus: er.GrpcCode(uint32(codes.InvalidArgument)), Severity: er.SeverityWarning},
+	)
+}
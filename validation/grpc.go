@@ -0,0 +1,18 @@
+package validation
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor validates requests implementing Validatable or having validation tags
+// it returns the same error as DecodeAndValidate, which is converted to InvalidArgument status with BadRequest details
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := Struct(ctx, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,112 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	ens "github.com/go-playground/validator/translations/en"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// DefaultTag is a struct tag holding validation rules
+const DefaultTag = "validate"
+
+// Validatable is implemented by types having custom validation, Validate() is called after validation by tags
+type Validatable interface {
+	Validate() error
+}
+
+// NewValidator creates validator using the given struct tag with english translations of error messages
+func NewValidator(tag string) (*validator.Validate, ut.Translator) {
+	v := validator.New()
+	v.SetTagName(tag)
+	uni := ut.New(en.New(), en.New())
+	trans, _ := uni.GetTranslator("en")
+	_ = ens.RegisterDefaultTranslations(v, trans)
+	return v, trans
+}
+
+var defaultValidator, defaultTrans = newDefaultValidator()
+
+// newDefaultValidator creates validator reporting fields by their JSON names
+func newDefaultValidator() (*validator.Validate, ut.Translator) {
+	v, trans := NewValidator(DefaultTag)
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return f.Name
+		}
+		return name
+	})
+	return v, trans
+}
+
+// Validator returns validator used by the package, e.g. to register custom validations
+func Validator() *validator.Validate {
+	return defaultValidator
+}
+
+// Struct validates struct by tags and calls Validate() if implemented
+// all failures are returned as a single AppError (HTTP 400, gRPC InvalidArgument) aggregating field errors
+func Struct(ctx context.Context, v interface{}) error {
+
+	var errs []error
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Struct {
+		if err := defaultValidator.StructCtx(ctx, v); err != nil {
+			fieldErrs, ok := err.(validator.ValidationErrors)
+			if !ok {
+				return ErrValidation(ctx, []error{ErrValidationFunc(err)})
+			}
+			for _, fe := range fieldErrs {
+				errs = append(errs, ErrValidationField(fieldPath(fe), fe.Tag(), fe.Param(), fe.Translate(defaultTrans)))
+			}
+		}
+	}
+
+	if vv, ok := v.(Validatable); ok {
+		if err := vv.Validate(); err != nil {
+			// app errors are kept as is, so that their codes and fields reach the client
+			if _, ok := er.Is(err); !ok {
+				err = ErrValidationFunc(err)
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return ErrValidation(ctx, errs)
+	}
+	return nil
+}
+
+// fieldPath returns path of the field without the top level struct name, e.g. items[0].name
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+// DecodeAndValidate decodes JSON body of the request to v and validates it
+func DecodeAndValidate(r *http.Request, ctx context.Context, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return ErrDecodeRequest(err, ctx)
+	}
+	return Struct(ctx, v)
+}
@@ -0,0 +1,69 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git.jetbrains.space/orbi/fcsd/kit/er"
+)
+
+type item struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type request struct {
+	Email string  `json:"email" validate:"required,email"`
+	Items []*item `json:"items" validate:"dive"`
+}
+
+func (r *request) Validate() error {
+	if len(r.Items) > 2 {
+		return errors.New("too many items")
+	}
+	return nil
+}
+
+func Test_DecodeAndValidate(t *testing.T) {
+	rq := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":"wrong","items":[{"name":""},{},{}]}`))
+
+	err := DecodeAndValidate(rq, context.Background(), &request{})
+
+	appErr, ok := er.Is(err)
+	if !ok || appErr.Code() != ErrCodeValidation {
+		t.Fatalf("validation error expected, got %v", err)
+	}
+	if st := appErr.HttpStatus(); st == nil || *st != http.StatusBadRequest {
+		t.Fatalf("unexpected http status %v", st)
+	}
+
+	var fields []string
+	for _, e := range appErr.Errors() {
+		a, _ := er.Is(e)
+		if f, ok := a.Fields()[er.FieldKeyField].(string); ok {
+			fields = append(fields, f)
+		}
+	}
+	if strings.Join(fields, ",") != "email,items[0].name,items[1].name,items[2].name" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	if !errors.Is(err, er.Code(ErrCodeValidationFunc)) {
+		t.Fatal("Validate() failure expected")
+	}
+}
+
+func Test_DecodeInvalidJson(t *testing.T) {
+	rq := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{`))
+	if err := DecodeAndValidate(rq, context.Background(), &request{}); !errors.Is(err, er.Code(ErrCodeDecodeRequest)) {
+		t.Fatalf("decode error expected, got %v", err)
+	}
+}
+
+func Test_Valid(t *testing.T) {
+	if err := Struct(context.Background(), &request{Email: "test@example.com", Items: []*item{{Name: "n"}}}); err != nil {
+		t.Fatal(err)
+	}
+}